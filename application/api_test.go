@@ -3,26 +3,45 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"gdzce.cn/perishable-food/application/auth"
 	"gdzce.cn/perishable-food/application/blockchain"
+	"gdzce.cn/perishable-food/application/controller"
+	"gdzce.cn/perishable-food/application/events"
+	"gdzce.cn/perishable-food/application/fbeecloud"
+	"gdzce.cn/perishable-food/application/lib"
 	"gdzce.cn/perishable-food/application/repository"
+	"gdzce.cn/perishable-food/application/updater"
+	"gdzce.cn/perishable-food/application/wallet"
 	"github.com/gin-gonic/gin"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
 )
 
 // 根据特定请求uri，发起get请求返回响应
 func get(uri string, router *gin.Engine) ([]byte, int) {
+	return getWithToken(uri, "", router)
+}
+
+// 根据特定请求uri，以token对应的登录用户发起get请求返回响应，token为空时不登录
+func getWithToken(uri string, token string, router *gin.Engine) ([]byte, int) {
 	// 构造get请求
 	req := httptest.NewRequest("GET", uri, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 	// 初始化响应
 	w := httptest.NewRecorder()
 	// 调用相应的handler接口
@@ -40,11 +59,19 @@ func get(uri string, router *gin.Engine) ([]byte, int) {
 	return body, result.StatusCode
 }
 
-// 根据特定请求uri和参数param，以表单形式传递参数，发起post请求返回响应
+// 根据特定请求uri和参数param，以表单形式传递参数，以测试管理员登录发起post请求返回响应
 func postForm(uri string, param []byte, router *gin.Engine) ([]byte, int) {
+	return postWithToken(uri, param, token, router)
+}
+
+// 使用指定的登录令牌发起post请求，令牌为空时不登录
+func postWithToken(uri string, param []byte, token string, router *gin.Engine) ([]byte, int) {
 	// 构造post请求
 	req := httptest.NewRequest("POST", uri, strings.NewReader(bytes.NewBuffer(param).String()))
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 	// 初始化响应
 	w := httptest.NewRecorder()
 	// 调用相应handler接口
@@ -93,33 +120,108 @@ func writeFile(filePath string, data string) {
 	write.Flush()
 }
 
-var routers *gin.Engine
+var (
+	routers *gin.Engine
+	token   string            // 测试管理员的登录令牌
+	tokens  map[string]string // 各角色测试账户的登录令牌，供货商1、物流商2、买家3、监管方4
+)
 
 // testing 初始化内容
 func init() {
 	routers = setupRouter()
-	blockchain.Init()
-	// 加载存储[{orderId，txid}]的json文件
-	repository.TransactionRecordList.FilePath = TransactionRecordFileName
-	_ = repository.TransactionRecordList.LoadTransactionRecords()
+	controller.Tokens, _ = auth.NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	token, _, _ = controller.Tokens.Issue(controller.AdminUser, "", auth.RoleAdmin)
+	tokens = make(map[string]string)
+	for accountId, role := range map[string]string{"1": lib.RoleSupplier, "2": lib.RoleCarrier, "3": lib.RoleBuyer, "4": lib.RoleRegulator} {
+		tokens[role], _, _ = controller.Tokens.Issue("user"+accountId, accountId, role)
+	}
+
+	// 在进程内运行链码，不需要区块链网络
+	config := blockchain.DefaultConfig()
+	config.Backend = blockchain.BackendMock
+	if err := blockchain.Init(config); err != nil {
+		panic(err)
+	}
+	seedLedger()
+}
+
+// 交易记录缓存写入临时目录，不修改项目中的transactionRecord.json
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "perishable-food-test")
+	if err != nil {
+		panic(err)
+	}
+	store, err := repository.OpenStore(repository.StoreJSON, filepath.Join(dir, TransactionRecordFileName))
+	if err != nil {
+		panic(err)
+	}
+	repository.TransactionRecordList = store
+
+	code := m.Run()
+	_ = store.Close()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// 在本地模拟账本中准备测试数据：供货商1的商品testcreateOrder，新建的订单1和3，运送中的订单2
+func seedLedger() {
+	as := func(accountId string) *blockchain.Identity {
+		id := blockchain.DefaultIdentity()
+		id.AccountId = accountId
+		return id
+	}
+	orderTime := time.Now().Format("2006-01-02 15:04:05")
+	deliverTime := time.Now().Add(72 * time.Hour).Format("2006-01-02 15:04:05")
+	for _, tx := range []struct {
+		id   *blockchain.Identity
+		fcn  string
+		args []string
+	}{
+		{as("1"), "createCommodity", []string{"testCommodity", "testcreateOrder", "testOrigin", "0", "10", "10", "1"}},
+		{as("3"), "createOrder", []string{"testcreateOrder", "1", orderTime, "New", "3", "1", "testAddress", deliverTime, "10", "2", "rate", "20"}},
+		{as("3"), "createOrder", []string{"testcreateOrder", "2", orderTime, "New", "3", "1", "testAddress", deliverTime, "10", "2", "rate", "20"}},
+		{as("3"), "createOrder", []string{"testcreateOrder", "3", orderTime, "New", "3", "1", "testAddress", deliverTime, "10", "2", "rate", "20"}},
+		{as("2"), "updateOrderStatus", []string{"2", "Processing", "2"}},
+	} {
+		args := make([][]byte, 0, len(tx.args))
+		for _, arg := range tx.args {
+			args = append(args, []byte(arg))
+		}
+		if _, err := blockchain.ChannelExecute(context.Background(), tx.id, tx.fcn, args); err != nil {
+			panic(fmt.Sprintf("%s error: %s", tx.fcn, err))
+		}
+	}
+}
+
+// 连接区块链网络中示例链码mycc的账本
+// 需要运行中的Fabric网络（deploy/start.sh），设置环境变量FABRIC_NETWORK_TEST=1时才运行
+func fabricLedger(t *testing.T) blockchain.Ledger {
+	if os.Getenv("FABRIC_NETWORK_TEST") == "" {
+		t.Skip("set FABRIC_NETWORK_TEST=1 to run against a running fabric network")
+	}
+	config := blockchain.DefaultConfig()
+	config.Contracts[0].Name = "mycc"
+	config.Contracts[0].Chaincode = "mycc"
+	_, ledgers, err := blockchain.NewLedgers(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ledgers["mycc"]
 }
 
 // Test_SDK SDK能否访问区块链网络
 func Test_SDK1(t *testing.T) {
 
-	blockchain.ChaincodeName = "mycc"
-	defer func() {
-		blockchain.ChaincodeName = "mychaincode"
-	}()
+	ledger := fabricLedger(t)
 
-	resp, errString := blockchain.ChannelExecute1("invoke", [][]byte{
+	resp, errString := ledger.Execute(context.Background(), blockchain.DefaultIdentity(), "invoke", [][]byte{
 		[]byte("a"),
 		[]byte("b"),
 		[]byte("10"),
 	})
 	if resp.ChaincodeStatus == 200 && errString == nil {
 		time.Sleep(2 * time.Second)
-		resp, _ := blockchain.ChannelQuery1("query", [][]byte{
+		resp, _ := ledger.Query(context.Background(), blockchain.DefaultIdentity(), "query", [][]byte{
 			[]byte("a"),
 		})
 		t.Logf("resp.Payload: %+v\n", bytes.NewBuffer(resp.Payload).String())
@@ -140,13 +242,10 @@ func Test_SDK1(t *testing.T) {
 
 // Test_SDK SDK是否符合全组织背书策略
 func Test_SDK2(t *testing.T) {
+	ledger := fabricLedger(t)
 	routers.GET("/testGet", func(c *gin.Context) {
 		key := c.Query("key")
-		blockchain.ChaincodeName = "mycc"
-		defer func() {
-			blockchain.ChaincodeName = "mychaincode"
-		}()
-		resp, _ := blockchain.ChannelQuery1("query", [][]byte{
+		resp, _ := ledger.Query(context.Background(), blockchain.DefaultIdentity(), "query", [][]byte{
 			[]byte(key),
 		})
 		// 将结果返回
@@ -168,16 +267,16 @@ func Test_SDK2(t *testing.T) {
 // 能调用链码创建商品
 func Test_createCommodity(t *testing.T) {
 	data := commodityRequest2{
-		Name:     "testCommodity1",
-		Id:       "20211001001",
-		Location: "testOrigin",
-		//LowTemperature:  "5",
-		//HighTemperature: "20",
-		Price:   10,
-		OwnerId: "1",
+		Name:            "testCommodity1",
+		Id:              "20211001001",
+		Location:        "testOrigin",
+		LowTemperature:  "5",
+		HighTemperature: "20",
+		Price:           10,
+		OwnerId:         "1",
 	}
 	arrByte, _ := json.Marshal(data)
-	_, status := postForm("/createCommodity", arrByte, routers)
+	_, status := postWithToken("/createCommodity", arrByte, tokens[lib.RoleSupplier], routers)
 	t.Log(status)
 	if status == 200 {
 		expectApi(1, "Test_createCommodity")
@@ -201,17 +300,23 @@ func Test_commodityList(t *testing.T) {
 
 // 能调用链码创建订单
 func Test_createOrder(t *testing.T) {
+	orderTime := time.Now().UnixNano() / 1e6
 	data := orderRequest2{
-		CommodityId: "testcreateOrder",
-		Id:          "20211001001",
-		//DeliverAddress: "testAddress",
-		OrderTime: 20211001001,
-		//Quantity:       10,
-		BuyerId:  "3",
-		SellerId: "1",
+		CommodityId:    "testcreateOrder",
+		Id:             "20211001001",
+		DeliverAddress: "testAddress",
+		OrderTime:      orderTime,
+		DeliverTime:    orderTime + 72*3600*1000,
+		Status:         "New",
+		Quantity:       10,
+		BuyerId:        "3",
+		SellerId:       "1",
+		CarrierId:      "2",
+		FreightType:    "rate",
+		Freight:        20,
 	}
 	arrByte, _ := json.Marshal(data)
-	_, status := postForm("/createOrder", arrByte, routers)
+	_, status := postWithToken("/createOrder", arrByte, tokens[lib.RoleBuyer], routers)
 	t.Log(status)
 	if status == 200 {
 		expectApi(1, "Test_createOrder")
@@ -223,7 +328,7 @@ func Test_createOrder(t *testing.T) {
 
 // 能根据订单id查询订单
 func Test_orderList1(t *testing.T) {
-	_, status := get("/orderList?orderId=1570885832799", routers)
+	_, status := getWithToken("/orderList?orderId=1570885832799", token, routers)
 	t.Log(status)
 	if status == 200 {
 		expectApi(1, "Test_orderList1")
@@ -235,7 +340,7 @@ func Test_orderList1(t *testing.T) {
 
 // 能查询所有订单
 func Test_orderList2(t *testing.T) {
-	_, status := get("/orderList", routers)
+	_, status := getWithToken("/orderList", token, routers)
 	t.Log(status)
 	if status == 200 {
 		expectApi(1, "Test_orderList2")
@@ -245,6 +350,28 @@ func Test_orderList2(t *testing.T) {
 	}
 }
 
+// 登录用户只能查询自己参与的订单，查询人由链码按调用者证书确定，不能在参数中指定
+func Test_orderList3(t *testing.T) {
+	arrByte, _ := json.Marshal(createAccountRequest2{Id: "8", Name: "冷链物流", Role: "carrier"})
+	if body, status := postForm("/createAccount", arrByte, routers); status != 200 {
+		t.Log(status, string(body))
+		expectApi(2, "Test_orderList3")
+		t.FailNow()
+	}
+	other, _, _ := controller.Tokens.Issue("user8", "8", lib.RoleCarrier)
+	for tok, all := range map[string]bool{other: false, tokens[lib.RoleRegulator]: true} {
+		body, status := getWithToken("/orderList?operator=2", tok, routers)
+		var orders []lib.Order
+		_ = json.Unmarshal(body, &orders)
+		if status != 200 || (len(orders) != 0) != all {
+			t.Log(status, string(body))
+			expectApi(2, "Test_orderList3")
+			t.FailNow()
+		}
+	}
+	expectApi(1, "Test_orderList3")
+}
+
 // 能查询账户信息
 func Test_accountList(t *testing.T) {
 	data := accountListRequestBody2{
@@ -261,13 +388,196 @@ func Test_accountList(t *testing.T) {
 	}
 }
 
+// 能创建、更新和注销账户
+func Test_manageAccount(t *testing.T) {
+	for _, req := range []struct {
+		uri  string
+		data interface{}
+	}{
+		{"/createAccount", createAccountRequest2{Id: "5", Name: "冷链物流", Balance: 500, Role: "carrier"}},
+		{"/updateAccount", updateAccountRequest2{Id: "5", Name: "冷链物流公司"}},
+		{"/deactivateAccount", deactivateAccountRequest2{Id: "5"}},
+	} {
+		arrByte, _ := json.Marshal(req.data)
+		body, status := postForm(req.uri, arrByte, routers)
+		t.Log(req.uri, status, string(body))
+		if status != 200 {
+			expectApi(2, "Test_manageAccount")
+			t.FailNow()
+		}
+	}
+
+	// 缺少账户名时请求无效
+	arrByte, _ := json.Marshal(createAccountRequest2{Id: "6"})
+	_, status := postForm("/createAccount", arrByte, routers)
+	if status != 400 {
+		expectApi(2, "Test_manageAccount")
+		t.FailNow()
+	}
+	expectApi(1, "Test_manageAccount")
+}
+
+// 管理员能用口令登录，口令错误时返回401
+func Test_login(t *testing.T) {
+	controller.AdminPassword = "adminpassword"
+	defer func() { controller.AdminPassword = "" }()
+
+	arrByte, _ := json.Marshal(loginRequest2{User: controller.AdminUser, Password: "adminpassword"})
+	body, status := postWithToken("/login", arrByte, "", routers)
+	resp := struct {
+		Token string `json:"token"`
+		Role  string `json:"role"`
+	}{}
+	_ = json.Unmarshal(body, &resp)
+	claims, err := controller.Tokens.Parse(resp.Token)
+	if status != 200 || err != nil || claims.Role != auth.RoleAdmin || resp.Role != auth.RoleAdmin {
+		t.Log(status, string(body))
+		expectApi(2, "Test_login")
+		t.FailNow()
+	}
+
+	// 本地模拟账本中账户以账户ID和管理员口令登录，令牌带有账户和角色，可以修改自己的账户
+	arrByte, _ = json.Marshal(loginRequest2{User: "3", Password: "adminpassword"})
+	body, status = postWithToken("/login", arrByte, "", routers)
+	_ = json.Unmarshal(body, &resp)
+	claims, err = controller.Tokens.Parse(resp.Token)
+	if status != 200 || err != nil || claims.AccountId != "3" || claims.Role != lib.RoleBuyer {
+		t.Log(status, string(body))
+		expectApi(2, "Test_login")
+		t.FailNow()
+	}
+	arrByte, _ = json.Marshal(updateAccountRequest2{Id: "3", Name: "买家"})
+	if body, status := postWithToken("/updateAccount", arrByte, resp.Token, routers); status != 200 {
+		t.Log(status, string(body))
+		expectApi(2, "Test_login")
+		t.FailNow()
+	}
+
+	for _, data := range []loginRequest2{
+		{User: controller.AdminUser, Password: "wrong"},
+		{User: "user1", Password: "adminpassword"},
+		{User: "3", Password: "wrong"},
+		{User: "99", Password: "adminpassword"},
+	} {
+		arrByte, _ := json.Marshal(data)
+		if _, status := postWithToken("/login", arrByte, "", routers); status != 401 {
+			t.Log(data.User, status)
+			expectApi(2, "Test_login")
+			t.FailNow()
+		}
+	}
+	expectApi(1, "Test_login")
+}
+
+// 未登录或令牌无效时不能修改数据，查询不需要登录
+func Test_requireAuth(t *testing.T) {
+	arrByte, _ := json.Marshal(createAccountRequest2{Id: "5", Name: "test", Role: "buyer"})
+	_, noToken := postWithToken("/createAccount", arrByte, "", routers)
+	_, badToken := postWithToken("/createAccount", arrByte, token+"x", routers)
+	_, query := get("/commodityList", routers)
+	// 订单和账户查询不能以默认的管理员身份匿名调用
+	_, orders := get("/orderList", routers)
+	arrByte, _ = json.Marshal(accountListRequestBody2{AccountId: "all"})
+	_, accounts := postWithToken("/accountList", arrByte, "", routers)
+	t.Log(noToken, badToken, query, orders, accounts)
+	if noToken == 401 && badToken == 401 && query == 200 && orders == 401 && accounts == 401 {
+		expectApi(1, "Test_requireAuth")
+	} else {
+		expectApi(2, "Test_requireAuth")
+		t.FailNow()
+	}
+}
+
+// 只对允许的来源返回跨域响应头
+func Test_CORS(t *testing.T) {
+	controller.AllowedOrigins = []string{"http://localhost:8081"}
+	defer func() { controller.AllowedOrigins = nil }()
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/createOrder", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		routers.ServeHTTP(w, req)
+		return w
+	}
+
+	allowed := preflight("http://localhost:8081")
+	denied := preflight("http://evil.example.com")
+	t.Log(allowed.Code, denied.Code)
+	if allowed.Code == 204 && allowed.Header().Get("Access-Control-Allow-Origin") == "http://localhost:8081" &&
+		denied.Code == 403 && denied.Header().Get("Access-Control-Allow-Origin") == "" {
+		expectApi(1, "Test_CORS")
+	} else {
+		expectApi(2, "Test_CORS")
+		t.FailNow()
+	}
+}
+
+// 角色不符或数据不属于登录账户时返回403和原因
+func Test_authorize(t *testing.T) {
+	orderTime := time.Now().UnixNano() / 1e6
+	order := orderRequest2{
+		CommodityId: "testcreateOrder", Id: "20211001002", DeliverAddress: "testAddress",
+		OrderTime: orderTime, DeliverTime: orderTime, Status: "New", Quantity: 1,
+		BuyerId: "3", SellerId: "1", CarrierId: "2", FreightType: "rate",
+	}
+	otherSeller := order
+	otherSeller.SellerId = "5"
+	otherBuyer := order
+	otherBuyer.BuyerId = "6"
+
+	for name, c := range map[string]struct {
+		uri    string
+		data   interface{}
+		token  string
+		reason string
+	}{
+		"buyer creates commodity":  {"/createCommodity", commodityRequest2{Id: "1", OwnerId: "3"}, tokens[lib.RoleBuyer], "role buyer is not allowed"},
+		"admin updates order":      {"/updateOrderStatus", updateOrderStatusRequest2{OrderId: "1", Status: "Done", Operator: "3"}, token, "role admin is not allowed"},
+		"supplier for other":       {"/createCommodity", commodityRequest2{Id: "1", OwnerId: "7"}, tokens[lib.RoleSupplier], "owner 7 is not the logged-in account 1"},
+		"buyer for other":          {"/createOrder", otherBuyer, tokens[lib.RoleBuyer], "buyer 6 is not the logged-in account 3"},
+		"commodity of other":       {"/createOrder", otherSeller, tokens[lib.RoleBuyer], "commodity testcreateOrder does not belong to seller 5"},
+		"order of other":           {"/updateOrderStatus", updateOrderStatusRequest2{OrderId: "9", Status: "Done", Operator: "3"}, tokens[lib.RoleBuyer], "order 9 not exists"},
+		"supplier as carrier":      {"/updateOrderTemperature", updateOrderTemperatureRequest2{OrderId: "1", Operator: "1"}, tokens[lib.RoleSupplier], "role supplier is not allowed"},
+		"carrier for other":        {"/updateOrderStatus", updateOrderStatusRequest2{OrderId: "1", Status: "Processing", Operator: "4"}, tokens[lib.RoleCarrier], "operator 4 is not the logged-in account 2"},
+		"non-admin user":           {"/admin/revokeUser", map[string]string{"user": "user1"}, tokens[lib.RoleSupplier], "role supplier is not allowed"},
+		"rename other account":     {"/updateAccount", updateAccountRequest2{Id: "3", Name: "test"}, tokens[lib.RoleSupplier], "id 3 is not the logged-in account 1"},
+		"query self, body other":   {"/updateOrderStatus?operator=2", updateOrderStatusRequest2{OrderId: "1", Status: "Processing", Operator: "4"}, tokens[lib.RoleCarrier], "operator 4 is not the logged-in account 2"},
+		"query self, rename other": {"/updateAccount?id=1", updateAccountRequest2{Id: "3", Name: "test"}, tokens[lib.RoleSupplier], "id 3 is not the logged-in account 1"},
+	} {
+		arrByte, _ := json.Marshal(c.data)
+		body, status := postWithToken(c.uri, arrByte, c.token, routers)
+		if status != 403 || !strings.Contains(string(body), c.reason) {
+			t.Log(name, status, string(body))
+			expectApi(2, "Test_authorize")
+			t.FailNow()
+		}
+	}
+
+	// 卖家不是订单的卖家时不能更新订单状态
+	loadOrder := controller.LoadOrder
+	controller.LoadOrder = func(ctx context.Context, orderId string) (*lib.Order, error) {
+		return &lib.Order{Id: orderId, SellerId: "9", CarrierId: "2", BuyerId: "3"}, nil
+	}
+	defer func() { controller.LoadOrder = loadOrder }()
+	arrByte, _ := json.Marshal(updateOrderStatusRequest2{OrderId: "1", Status: "Canceled", Operator: "1"})
+	body, status := postWithToken("/updateOrderStatus", arrByte, tokens[lib.RoleSupplier], routers)
+	if status != 403 || !strings.Contains(string(body), "account 1 is not the supplier of order 1") {
+		t.Log(status, string(body))
+		expectApi(2, "Test_authorize")
+		t.FailNow()
+	}
+	expectApi(1, "Test_authorize")
+}
+
 func Test_updateOrderStatus(t *testing.T) {
 	data := updateOrderStatusRequest2{
-		OrderId: "1",
-		Status:  "Processing",
+		OrderId:  "1",
+		Status:   "Processing",
+		Operator: "2",
 	}
 	arrByte, _ := json.Marshal(data)
-	_, status := postForm("/updateOrderStatus", arrByte, routers)
+	_, status := postWithToken("/updateOrderStatus", arrByte, tokens[lib.RoleCarrier], routers)
 	t.Log(status)
 	if status == 200 {
 		expectApi(1, "Test_updateOrderStatus")
@@ -277,32 +587,296 @@ func Test_updateOrderStatus(t *testing.T) {
 	}
 }
 
+// 能上传一条或多条温度读数
+func Test_updateOrderTemperature(t *testing.T) {
+	value := -1.5
+	data := updateOrderTemperatureRequest2{
+		OrderId:  "2",
+		Operator: "2",
+		Readings: []temperatureReading2{
+			{Value: &value, RecordTime: time.Now().UnixNano() / 1e6, SensorId: "s1"},
+			{Value: &value, Unit: "F", RecordTime: time.Now().UnixNano() / 1e6},
+		},
+	}
+	arrByte, _ := json.Marshal(data)
+	_, status := postWithToken("/updateOrderTemperature", arrByte, tokens[lib.RoleCarrier], routers)
+	t.Log(status)
+
+	// 缺少温度值时请求无效
+	data.Readings[0].Value = nil
+	arrByte, _ = json.Marshal(data)
+	_, badStatus := postWithToken("/updateOrderTemperature", arrByte, tokens[lib.RoleCarrier], routers)
+	t.Log(badStatus)
+	if status == 200 && badStatus == 400 {
+		expectApi(1, "Test_updateOrderTemperature")
+	} else {
+		expectApi(2, "Test_updateOrderTemperature")
+		t.FailNow()
+	}
+}
+
+// 温度的记录时间与服务器时区无关，并保留毫秒
+func Test_temperatureTime(t *testing.T) {
+	value := 3.5
+	recordTime := time.Date(2021, 10, 2, 10, 0, 0, 123e6, time.FixedZone("CST", 8*3600))
+	data := updateOrderTemperatureRequest2{
+		OrderId:  "2",
+		Operator: "2",
+		Readings: []temperatureReading2{{Value: &value, RecordTime: recordTime.UnixNano() / 1e6, SensorId: "time"}},
+	}
+	arrByte, _ := json.Marshal(data)
+	_, status := postWithToken("/updateOrderTemperature", arrByte, tokens[lib.RoleCarrier], routers)
+
+	body, _ := getWithToken("/orderList?orderId=2", token, routers)
+	var orders []lib.Order
+	_ = json.Unmarshal(body, &orders)
+	if status == 200 && len(orders) == 1 {
+		for _, temperature := range orders[0].TemperatureVariation {
+			if temperature.SensorId == "time" && temperature.RecordTime.Equal(recordTime) {
+				expectApi(1, "Test_temperatureTime")
+				return
+			}
+		}
+	}
+	expectApi(2, "Test_temperatureTime")
+	t.Fatalf("reading at %s not recorded: %d %s", recordTime, status, body)
+}
+
+// Fabric账本上没有登记身份的账户不以默认身份上传温度
+func Test_accountIdentity(t *testing.T) {
+	backend := blockchain.Current.Backend
+	blockchain.Current.Backend = blockchain.BackendFabric
+	defer func() { blockchain.Current.Backend = backend }()
+
+	id, err := controller.AccountIdentity("2")
+	if id != nil || !errors.Is(err, wallet.ErrNotFound) {
+		t.Log(id, err)
+		expectApi(2, "Test_accountIdentity")
+		t.FailNow()
+	}
+	if err := controller.SubmitTemperature("3", "2", &fbeecloud.Reading{Temperature: 4, RecordTime: time.Now()}); !errors.Is(err, wallet.ErrNotFound) {
+		t.Log(err)
+		expectApi(2, "Test_accountIdentity")
+		t.FailNow()
+	}
+	expectApi(1, "Test_accountIdentity")
+}
+
+// 温度更新器上传温度，订单不在运送中时返回ErrOrderClosed以停止更新器
+func Test_submitTemperature(t *testing.T) {
+	reading := &fbeecloud.Reading{SensorId: "s1", Temperature: 4, RecordTime: time.Now()}
+	err := controller.SubmitTemperature("2", "2", reading)
+	closedErr := controller.SubmitTemperature("3", "2", reading)
+	if err == nil && errors.Is(closedErr, updater.ErrOrderClosed) {
+		expectApi(1, "Test_submitTemperature")
+	} else {
+		expectApi(2, "Test_submitTemperature")
+		t.Fatalf("unexpected errors %v, %v", err, closedErr)
+	}
+}
+
+// 事件监听启动后，浏览器通过/events收到新建订单的事件，未参与订单的账户收不到
+func Test_events(t *testing.T) {
+	source, err := blockchain.ContractEvents(blockchain.Current.DefaultContract().Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	listener := &events.Listener{Source: source, Hub: events.NewHub()}
+	controller.EventHub = listener.Hub
+	defer func() { controller.EventHub = nil }()
+	go listener.Run(ctx)
+
+	server := httptest.NewServer(routers)
+	defer server.Close()
+	subscribe := func(uri, token string) *http.Response {
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+uri, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// 未登录不能订阅
+	resp := subscribe("/events", "")
+	resp.Body.Close()
+	if resp.StatusCode != 401 {
+		expectApi(2, "Test_events")
+		t.Fatalf("unexpected status %d without login", resp.StatusCode)
+	}
+
+	resp = subscribe("/events?type=OrderCreated", tokens[lib.RoleBuyer])
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		expectApi(2, "Test_events")
+		t.Fatalf("unexpected response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// EventSource不能设置请求头，令牌放在查询参数中
+	other, _, _ := controller.Tokens.Issue("user6", "6", lib.RoleBuyer)
+	otherResp := subscribe("/events?type=OrderCreated,CommodityCreated&access_token="+other, "")
+	defer otherResp.Body.Close()
+	if otherResp.StatusCode != 200 {
+		expectApi(2, "Test_events")
+		t.Fatalf("unexpected status %d with access_token", otherResp.StatusCode)
+	}
+
+	// 订阅建立后再下单
+	for listener.Hub.Len() < 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	orderTime := time.Now().Format("2006-01-02 15:04:05")
+	deliverTime := time.Now().Add(72 * time.Hour).Format("2006-01-02 15:04:05")
+	id := blockchain.DefaultIdentity()
+	id.AccountId = "3"
+	args := [][]byte{}
+	for _, arg := range []string{"testcreateOrder", "events1", orderTime, "New", "3", "1", "testAddress", deliverTime, "10", "2", "rate", "20"} {
+		args = append(args, []byte(arg))
+	}
+	if _, err := blockchain.ChannelExecute(ctx, id, "createOrder", args); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只订阅了OrderCreated，种子数据中的订单也会推送，直到收到新订单
+	received := false
+	scanner := bufio.NewScanner(resp.Body)
+	for !received && scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event:") && strings.TrimSpace(strings.TrimPrefix(line, "event:")) != "OrderCreated" {
+			expectApi(2, "Test_events")
+			t.Fatalf("unexpected event %s", line)
+		}
+		received = strings.HasPrefix(line, "data:") && strings.Contains(line, `"orderId":"events1"`)
+	}
+	if !received {
+		expectApi(2, "Test_events")
+		t.Fatalf("order event not received: %v", scanner.Err())
+	}
+
+	// 商品事件推送给所有登录用户，在此之前其他账户不应收到任何订单事件
+	data, _ := json.Marshal(commodityRequest2{Name: "events", Id: "events1", Location: "testLocation", LowTemperature: "1", HighTemperature: "5", Price: 1, OwnerId: "1"})
+	if _, status := postWithToken("/createCommodity", data, tokens[lib.RoleSupplier], routers); status != 200 {
+		t.Fatalf("create commodity status %d", status)
+	}
+	scanner = bufio.NewScanner(otherResp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event:") && strings.TrimSpace(strings.TrimPrefix(line, "event:")) != "CommodityCreated" {
+			expectApi(2, "Test_events")
+			t.Fatalf("unexpected event %s for account 6", line)
+		}
+		if strings.HasPrefix(line, "data:") && strings.Contains(line, `"commodityId":"events1"`) {
+			expectApi(1, "Test_events")
+			return
+		}
+	}
+	expectApi(2, "Test_events")
+	t.Fatalf("commodity event not received: %v", scanner.Err())
+}
+
 type commodityRequest2 struct {
-	Name     string `json:"name" form:"name" binding:"required"`         // 商品名
-	Id       string `json:"id" form:"id" binding:"required"`             // id
-	Location string `json:"location" form:"location" binding:"required"` // 产地
-	//LowTemperature  string  `json:"lowTemperature" form:"lowTemperature" binding:"required"`   // 最低温
-	//HighTemperature string  `json:"highTemperature" form:"highTemperature" binding:"required"` // 最高温
-	Price   float64 `json:"price" form:"price" binding:"required"` // 单价
-	OwnerId string  `json:"owner" form:"owner" binding:"required"` // 所有者
+	Name            string  `json:"name" form:"name" binding:"required"`                       // 商品名
+	Id              string  `json:"id" form:"id" binding:"required"`                           // id
+	Location        string  `json:"location" form:"location" binding:"required"`               // 产地
+	LowTemperature  string  `json:"lowTemperature" form:"lowTemperature" binding:"required"`   // 最低温
+	HighTemperature string  `json:"highTemperature" form:"highTemperature" binding:"required"` // 最高温
+	Price           float64 `json:"price" form:"price" binding:"required"`                     // 单价
+	OwnerId         string  `json:"owner" form:"owner" binding:"required"`                     // 所有者
 }
 
 type orderRequest2 struct {
-	CommodityId string `json:"commodity_id" binding:"required"` // 商品id
-	Id          string `json:"id" binding:"required"`           // 订单id
-	//DeliverAddress string `json:"deliverAddress" binding:"required"` // 配送地址
-	OrderTime int64  `json:"orderTime" binding:"required"` // 预计送达时间（时间戳）
-	Status    string `json:"status" binding:"required"`    // 预计送达时间（时间戳）
-	//Quantity       int64  `json:"quantity" binding:"required"`       // 数量
-	BuyerId  string `json:"buyer" binding:"required"`  // 买家
-	SellerId string `json:"seller" binding:"required"` // 卖家
+	CommodityId    string  `json:"commodity_id" binding:"required"`   // 商品id
+	Id             string  `json:"id" binding:"required"`             // 订单id
+	DeliverAddress string  `json:"deliverAddress" binding:"required"` // 配送地址
+	OrderTime      int64   `json:"orderTime" binding:"required"`      // 下单时间（时间戳）
+	DeliverTime    int64   `json:"deliverTime" binding:"required"`    // 预计送达时间（时间戳）
+	Status         string  `json:"status" binding:"required"`         // 订单状态
+	Quantity       int64   `json:"quantity" binding:"required"`       // 数量
+	BuyerId        string  `json:"buyer" binding:"required"`          // 买家
+	SellerId       string  `json:"seller" binding:"required"`         // 卖家
+	CarrierId      string  `json:"carrier" binding:"required"`        // 物流商
+	FreightType    string  `json:"freightType" binding:"required"`    // 物流费计算方式
+	Freight        float64 `json:"freight"`                           // 物流费百分比或固定运费
+}
+
+type loginRequest2 struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
 }
 
 type accountListRequestBody2 struct {
 	AccountId string `form:"account_id" json:"account_id" binding:"required"`
 }
 
+type createAccountRequest2 struct {
+	Id      string  `json:"id"`
+	Name    string  `json:"name"`
+	Balance float64 `json:"balance"`
+	Role    string  `json:"role"`
+}
+
+type updateAccountRequest2 struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type deactivateAccountRequest2 struct {
+	Id string `json:"id"`
+}
+
 type updateOrderStatusRequest2 struct {
-	OrderId string `form:"order_id" json:"order_id" binding:"required"`
-	Status  string `form:"status" json:"status" binding:"required"`
+	OrderId  string `form:"order_id" json:"order_id" binding:"required"`
+	Status   string `form:"status" json:"status" binding:"required"`
+	Operator string `form:"operator" json:"operator" binding:"required"`
+}
+
+type temperatureReading2 struct {
+	Value      *float64 `json:"value" binding:"required"`
+	Unit       string   `json:"unit" binding:"omitempty,oneof=C F"`
+	RecordTime int64    `json:"record_time" binding:"required,min=1"`
+	SensorId   string   `json:"sensor_id"`
+}
+
+type updateOrderTemperatureRequest2 struct {
+	OrderId  string                `json:"order_id" binding:"required"`
+	Operator string                `json:"operator" binding:"required"`
+	Readings []temperatureReading2 `json:"readings" binding:"required,min=1,dive"`
+}
+
+// 从区块同步订单的交易记录后，订单列表返回该订单的所有交易
+func Test_transactionRecords(t *testing.T) {
+	source, err := blockchain.ContractEvents(blockchain.Current.DefaultContract().Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	records := repository.TransactionRecordList
+	listener := &events.Listener{Source: source, From: records.NextBlock(), Handlers: []events.Handler{events.RecordTransactions(records)}}
+	go listener.Run(ctx)
+
+	// 订单2在准备数据时创建，随后改为运送中并上传了温度
+	for ctx.Err() == nil {
+		body, _ := getWithToken("/orderList?orderId=2", token, routers)
+		var orders []lib.Order
+		_ = json.Unmarshal(body, &orders)
+		if len(orders) == 1 && len(orders[0].Transactions) >= 3 {
+			txs := orders[0].Transactions
+			if txs[0].Event != "OrderCreated" || txs[0].TxId != orders[0].TransactionId || txs[1].Event != "OrderStatusChanged" || txs[1].Block <= txs[0].Block {
+				expectApi(2, "Test_transactionRecords")
+				t.Fatalf("unexpected transactions %s", body)
+			}
+			expectApi(1, "Test_transactionRecords")
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expectApi(2, "Test_transactionRecords")
+	t.Fatal("transactions not synced")
 }
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 令牌相关配置
+var (
+	SecretEnv = "JWT_SECRET"      // 保存签名密钥的环境变量
+	Issuer    = "perishable-food" // 令牌签发者
+	Expiry    = 12 * time.Hour    // 令牌有效期
+)
+
+// 应用程序的管理员角色，只存在于应用程序中，链码中没有对应的账户
+const RoleAdmin = "admin"
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// 令牌中携带的登录信息
+type Claims struct {
+	AccountId string `json:"account"` // 链上账户ID，管理员为空
+	Role      string `json:"role"`    // 账户角色
+	jwt.RegisteredClaims
+}
+
+// 登录的用户名
+func (c *Claims) User() string {
+	return c.Subject
+}
+
+// 签发和校验令牌，使用HMAC-SHA256签名
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret []byte) (*Signer, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("jwt secret must be at least 32 bytes")
+	}
+	return &Signer{secret: secret}, nil
+}
+
+// 使用环境变量中的密钥，未设置时随机生成，重启后之前签发的令牌失效
+func InitSigner() (*Signer, error) {
+	if secret := os.Getenv(SecretEnv); secret != "" {
+		return NewSigner([]byte(secret))
+	}
+	fmt.Printf("未设置%s，使用随机密钥签发令牌\n", SecretEnv)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return NewSigner(secret)
+}
+
+// 为用户签发令牌，返回令牌和过期时间
+func (s *Signer) Issue(user, accountId, role string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(Expiry)
+	claims := &Claims{
+		AccountId: accountId,
+		Role:      role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Subject:   user,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	return token, expiresAt, err
+}
+
+// 校验令牌的签名、签发者和有效期
+func (s *Signer) Parse(token string) (*Claims, error) {
+	claims := new(Claims)
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return s.secret, nil
+	})
+	if err != nil || !parsed.Valid || !claims.VerifyIssuer(Issuer, true) || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
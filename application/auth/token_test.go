@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newSigner(t *testing.T, secret []byte) *Signer {
+	s, err := NewSigner(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// 签发的令牌能解析出用户、账户和角色
+func TestToken1(t *testing.T) {
+	s := newSigner(t, testSecret)
+	token, expiresAt, err := s.Issue("user1", "3", "buyer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expiresAt.Before(time.Now()) {
+		t.Fatalf("unexpected expiry %v", expiresAt)
+	}
+
+	claims, err := s.Parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.User() != "user1" || claims.AccountId != "3" || claims.Role != "buyer" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+// 过期、篡改、密钥不同或签名算法不对的令牌无效
+func TestToken2(t *testing.T) {
+	s := newSigner(t, testSecret)
+
+	expiry := Expiry
+	Expiry = -time.Minute
+	expired, _, _ := s.Issue("user1", "3", "buyer")
+	Expiry = expiry
+
+	token, _, _ := s.Issue("user1", "3", "buyer")
+	parts := strings.Split(token, ".")
+	forged, _, _ := newSigner(t, []byte(strings.Repeat("x", 32))).Issue("user1", "", RoleAdmin)
+	forgedParts := strings.Split(forged, ".")
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, &Claims{
+		Role:             RoleAdmin,
+		RegisteredClaims: jwt.RegisteredClaims{Issuer: Issuer, Subject: "user1"},
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	for name, token := range map[string]string{
+		"expired":  expired,
+		"tampered": forgedParts[0] + "." + forgedParts[1] + "." + parts[2],
+		"forged":   forged,
+		"none":     none,
+		"garbage":  "token",
+	} {
+		if _, err := s.Parse(token); err != ErrInvalidToken {
+			t.Fatalf("%s: expected %v, got %v", name, ErrInvalidToken, err)
+		}
+	}
+
+	if _, err := NewSigner([]byte("short")); err == nil {
+		t.Fatal("expected error for short secret")
+	}
+}
+
+// 口令哈希校验
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(hash, "password1") || CheckPassword(hash, "password2") || CheckPassword(nil, "") {
+		t.Fatal("unexpected password check result")
+	}
+}
@@ -0,0 +1,228 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+)
+
+const (
+	ConfigPath = "ledger.json"   // 账本配置文件路径
+	ConfigEnv  = "LEDGER_CONFIG" // 指定账本配置文件路径的环境变量
+	Discovery  = "discovery"     // 环境变量中表示通过服务发现选择背书节点
+)
+
+// 覆盖配置文件的环境变量，通道、链码和背书节点作用于默认合约
+const (
+	envBackend   = "LEDGER_BACKEND"
+	envSDKConfig = "LEDGER_SDK_CONFIG"
+	envOrg       = "LEDGER_ORG"
+	envUser      = "LEDGER_USER"
+	envChannel   = "LEDGER_CHANNEL"
+	envChaincode = "LEDGER_CHAINCODE"
+	envEndorsers = "LEDGER_ENDORSERS"
+)
+
+// 账本配置，对应ledger.json
+type Config struct {
+	Backend   string           // 账本后端，fabric或mock，为空时使用fabric
+	SDKConfig string           // fabric-sdk的配置文件路径
+	Org       string           // 默认组织名称
+	User      string           // 默认用户，即配置文件中该组织的用户
+	Timeout   Duration         // 单次调用链码的超时时间，包括重试
+	Retry     RetryConfig      // 背书失败等临时错误的重试策略
+	Contracts []ContractConfig // 可调用的合约，每个合约为一个通道上的一个链码
+	Default   string           // 默认合约名称，为空时使用第一个合约
+}
+
+// 合约配置
+type ContractConfig struct {
+	Name      string   // 合约名称，为空时使用链码名称
+	Channel   string   // 通道名称
+	Chaincode string   // 链码名称
+	Endorsers []string // 背书节点，为空时通过服务发现选择满足背书策略的节点
+}
+
+// 重试策略，退避时间按BackoffFactor倍数增长，不超过MaxBackoff
+type RetryConfig struct {
+	Attempts       int      // 重试次数，为0时不重试
+	InitialBackoff Duration // 第一次重试前的等待时间
+	MaxBackoff     Duration // 最长等待时间
+	BackoffFactor  float64  // 每次重试等待时间的倍数
+}
+
+// 时长，配置文件中写作"30s"、"500ms"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var val string
+	if err := json.Unmarshal(data, &val); err != nil {
+		return fmt.Errorf("duration should be a string like \"30s\": %s", data)
+	}
+	duration, err := time.ParseDuration(val)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// 默认配置，即项目deploy目录中的网络：一个通道，全组织背书
+func DefaultConfig() Config {
+	return Config{
+		Backend:   BackendFabric,
+		SDKConfig: "./config.yaml",
+		Org:       "org1",
+		User:      "Admin",
+		Timeout:   Duration(30 * time.Second),
+		Retry: RetryConfig{
+			Attempts:       3,
+			InitialBackoff: Duration(500 * time.Millisecond),
+			MaxBackoff:     Duration(5 * time.Second),
+			BackoffFactor:  2,
+		},
+		Contracts: []ContractConfig{{
+			Name:      "perishable-food",
+			Channel:   "mychannel",
+			Chaincode: "mychaincode",
+			Endorsers: []string{ // 每个组织一个，满足全组织背书策略
+				"node1.organization1.gdzce.cn",
+				"node1.organization2.gdzce.cn",
+				"node1.organization3.gdzce.cn",
+			},
+		}},
+	}
+}
+
+// 从环境变量LEDGER_CONFIG指定的文件加载配置，未指定时使用ledger.json
+func InitConfig() (Config, error) {
+	path := os.Getenv(ConfigEnv)
+	if path == "" {
+		path = ConfigPath
+	}
+	return LoadConfig(path)
+}
+
+// 读取配置文件，文件中没有的字段使用默认配置，文件不存在时只使用默认配置和环境变量
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	file, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return config, err
+	}
+	if err == nil {
+		// 文件中的合约完整替换默认合约，不与默认合约的字段合并
+		contracts := config.Contracts
+		config.Contracts = nil
+		if err := json.Unmarshal(file, &config); err != nil {
+			return config, fmt.Errorf("ledger: parse %s error: %s", path, err)
+		}
+		if config.Contracts == nil {
+			config.Contracts = contracts
+		}
+	}
+	config.applyEnv()
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("ledger: %s: %s", path, err)
+	}
+	return config, nil
+}
+
+// 用环境变量覆盖配置
+func (c *Config) applyEnv() {
+	set := func(field *string, env string) {
+		if val := os.Getenv(env); val != "" {
+			*field = val
+		}
+	}
+	set(&c.Backend, envBackend)
+	set(&c.SDKConfig, envSDKConfig)
+	set(&c.Org, envOrg)
+	set(&c.User, envUser)
+
+	contract := c.DefaultContract()
+	if contract == nil {
+		return
+	}
+	set(&contract.Channel, envChannel)
+	set(&contract.Chaincode, envChaincode)
+	if val := os.Getenv(envEndorsers); val == Discovery {
+		contract.Endorsers = nil
+	} else if val != "" {
+		contract.Endorsers = nil
+		for _, endorser := range strings.Split(val, ",") {
+			if endorser = strings.TrimSpace(endorser); endorser != "" {
+				contract.Endorsers = append(contract.Endorsers, endorser)
+			}
+		}
+	}
+}
+
+// 校验配置，并补全合约名称
+func (c *Config) Validate() error {
+	switch c.Backend {
+	case "", BackendFabric, BackendMock:
+	default:
+		return fmt.Errorf("unknown ledger backend %s", c.Backend)
+	}
+	if c.Org == "" || c.User == "" {
+		return fmt.Errorf("Org and User are required")
+	}
+	if len(c.Contracts) == 0 {
+		return fmt.Errorf("at least one contract is required")
+	}
+	names := make(map[string]bool)
+	for i := range c.Contracts {
+		contract := &c.Contracts[i]
+		if contract.Channel == "" || contract.Chaincode == "" {
+			return fmt.Errorf("contract %d: Channel and Chaincode are required", i)
+		}
+		contract.Name = contract.name()
+		if names[contract.Name] {
+			return fmt.Errorf("duplicate contract %s", contract.Name)
+		}
+		names[contract.Name] = true
+	}
+	if c.Default != "" && !names[c.Default] {
+		return fmt.Errorf("default contract %s not exists", c.Default)
+	}
+	return nil
+}
+
+// 默认合约，Default为空时为第一个合约
+func (c *Config) DefaultContract() *ContractConfig {
+	for i := range c.Contracts {
+		if c.Default == "" || c.Contracts[i].name() == c.Default {
+			return &c.Contracts[i]
+		}
+	}
+	return nil
+}
+
+// 合约名称，为空时使用链码名称
+func (c ContractConfig) name() string {
+	if c.Name == "" {
+		return c.Chaincode
+	}
+	return c.Name
+}
+
+// 转换为sdk的重试选项
+func (r RetryConfig) opts() retry.Opts {
+	return retry.Opts{
+		Attempts:       r.Attempts,
+		InitialBackoff: time.Duration(r.InitialBackoff),
+		MaxBackoff:     time.Duration(r.MaxBackoff),
+		BackoffFactor:  r.BackoffFactor,
+		RetryableCodes: retry.ChannelClientRetryableCodes,
+	}
+}
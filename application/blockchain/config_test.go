@@ -0,0 +1,108 @@
+package blockchain
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 配置文件不存在时使用默认配置，文件中的字段覆盖默认配置
+func TestLoadConfig1(t *testing.T) {
+	config, err := LoadConfig(filepath.Join(t.TempDir(), "ledger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DefaultContract().Name != "perishable-food" || len(config.DefaultContract().Endorsers) != 3 {
+		t.Fatalf("unexpected default config %+v", config)
+	}
+
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	_ = ioutil.WriteFile(path, []byte(`{
+		"Timeout": "5s",
+		"Contracts": [
+			{"Channel": "mychannel", "Chaincode": "mychaincode"},
+			{"Name": "archive", "Channel": "archivechannel", "Chaincode": "mychaincode", "Endorsers": ["node1.organization1.gdzce.cn"]}
+		],
+		"Default": "archive"
+	}`), 0644)
+	config, err = LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(config.Timeout) != 5*time.Second || config.Org != "org1" || config.Retry.Attempts != 3 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	if config.Contracts[0].Name != "mychaincode" || len(config.Contracts[0].Endorsers) != 0 {
+		t.Fatalf("unexpected contract %+v", config.Contracts[0])
+	}
+	if config.DefaultContract().Channel != "archivechannel" {
+		t.Fatalf("unexpected default contract %+v", config.DefaultContract())
+	}
+}
+
+// 环境变量覆盖默认合约，discovery表示通过服务发现选择背书节点
+func TestLoadConfig2(t *testing.T) {
+	for env, val := range map[string]string{envBackend: BackendMock, envOrg: "org2", envChannel: "otherchannel", envEndorsers: Discovery} {
+		os.Setenv(env, val)
+		defer os.Unsetenv(env)
+	}
+	config, err := LoadConfig(filepath.Join(t.TempDir(), "ledger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract := config.DefaultContract()
+	if config.Backend != BackendMock || config.Org != "org2" || contract.Channel != "otherchannel" || contract.Endorsers != nil {
+		t.Fatalf("unexpected config %+v", config)
+	}
+
+	os.Setenv(envEndorsers, "node1.organization1.gdzce.cn, node1.organization2.gdzce.cn")
+	config, _ = LoadConfig(filepath.Join(t.TempDir(), "ledger.json"))
+	if endorsers := config.DefaultContract().Endorsers; len(endorsers) != 2 || endorsers[1] != "node1.organization2.gdzce.cn" {
+		t.Fatalf("unexpected endorsers %v", endorsers)
+	}
+}
+
+// 非法配置
+func TestValidate(t *testing.T) {
+	for want, modify := range map[string]func(*Config){
+		"unknown ledger backend": func(c *Config) { c.Backend = "couchdb" },
+		"required":               func(c *Config) { c.Contracts[0].Channel = "" },
+		"duplicate contract":     func(c *Config) { c.Contracts = append(c.Contracts, c.Contracts[0]) },
+		"default contract":       func(c *Config) { c.Default = "other" },
+		"at least one contract":  func(c *Config) { c.Contracts = nil },
+	} {
+		config := DefaultConfig()
+		modify(&config)
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q, got %v", want, err)
+		}
+	}
+}
+
+// 同时访问多个合约，各合约的账本互不影响
+func TestNewLedgers(t *testing.T) {
+	config := DefaultConfig()
+	config.Backend = BackendMock
+	config.Contracts = append(config.Contracts, ContractConfig{Name: "archive", Channel: "archivechannel", Chaincode: "mychaincode"})
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	_, ledgers, err := NewLedgers(config)
+	if err != nil || len(ledgers) != 2 {
+		t.Fatalf("expected 2 ledgers, got %d, %v", len(ledgers), err)
+	}
+
+	ctx := context.Background()
+	args := [][]byte{[]byte("c1"), []byte("carrier"), []byte("100"), []byte("carrier")}
+	if _, err := ledgers["archive"].Execute(ctx, nil, "createAccount", args); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ledgers["perishable-food"].Query(ctx, nil, "queryAccount", [][]byte{[]byte("c1")})
+	if err != nil || strings.Contains(string(resp.Payload), `"c1"`) {
+		t.Fatalf("contracts should not share state: %s, %v", resp.Payload, err)
+	}
+}
@@ -0,0 +1,166 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+)
+
+// 链码事件，事件名即链码中的事件类型，内容为链码写入的JSON
+type Event struct {
+	Block   uint64 // 所在区块
+	TxId    string // 交易ID
+	Name    string // 事件名
+	Payload []byte // 事件内容
+}
+
+// 已提交的区块，只包含本合约有效交易的链码事件
+type Block struct {
+	Number uint64
+	Events []*Event
+}
+
+// 区块事件源
+type EventSource interface {
+	// 从区块from开始按顺序推送区块，ctx取消或连接断开时关闭通道
+	Blocks(ctx context.Context, from uint64) (<-chan *Block, error)
+}
+
+// 按名称获取合约的事件源
+func ContractEvents(name string) (EventSource, error) {
+	ledger, err := Contract(name)
+	if err != nil {
+		return nil, err
+	}
+	source, ok := ledger.(EventSource)
+	if !ok {
+		return nil, fmt.Errorf("contract %s has no event source", name)
+	}
+	return source, nil
+}
+
+// 以默认身份通过sdk的事件客户端订阅区块，需要有接收完整区块的权限
+func (l *FabricLedger) Blocks(ctx context.Context, from uint64) (<-chan *Block, error) {
+	cli, err := event.New(l.Clients.SDK.ChannelContext(l.Channel, DefaultIdentity().options()...),
+		event.WithBlockEvents(), event.WithSeekType(seek.FromBlock), event.WithBlockNum(from))
+	if err != nil {
+		return nil, err
+	}
+	reg, events, err := cli.RegisterBlockEvent()
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make(chan *Block)
+	go func() {
+		defer close(blocks)
+		defer cli.Unregister(reg)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case val, ok := <-events:
+				if !ok {
+					return
+				}
+				block, err := parseBlock(val.Block, l.Chaincode)
+				if err != nil {
+					fmt.Printf("解析区块失败：%s\n", err)
+					return
+				}
+				// 重连后事件服务可能重复推送已处理的区块
+				if block.Number < from {
+					continue
+				}
+				select {
+				case blocks <- block:
+					from = block.Number + 1
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return blocks, nil
+}
+
+// 从区块中取出链码的事件，跳过验证失败的交易
+func parseBlock(block *cb.Block, chaincode string) (*Block, error) {
+	result := &Block{Number: block.GetHeader().GetNumber()}
+
+	var filter []byte
+	if metadata := block.GetMetadata().GetMetadata(); len(metadata) > int(cb.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		filter = metadata[cb.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+	for i, data := range block.GetData().GetData() {
+		if i < len(filter) && pb.TxValidationCode(filter[i]) != pb.TxValidationCode_VALID {
+			continue
+		}
+		txId, ccEvent, err := parseTransaction(data)
+		if err != nil {
+			return nil, fmt.Errorf("block %d tx %d: %s", result.Number, i, err)
+		}
+		if ccEvent == nil || ccEvent.GetChaincodeId() != chaincode || ccEvent.GetEventName() == "" {
+			continue
+		}
+		result.Events = append(result.Events, &Event{
+			Block:   result.Number,
+			TxId:    txId,
+			Name:    ccEvent.GetEventName(),
+			Payload: ccEvent.GetPayload(),
+		})
+	}
+	return result, nil
+}
+
+// 解析背书交易中的链码事件，配置交易等其他交易没有事件
+func parseTransaction(data []byte) (string, *pb.ChaincodeEvent, error) {
+	envelope := new(cb.Envelope)
+	if err := proto.Unmarshal(data, envelope); err != nil {
+		return "", nil, err
+	}
+	payload := new(cb.Payload)
+	if err := proto.Unmarshal(envelope.GetPayload(), payload); err != nil {
+		return "", nil, err
+	}
+	header := new(cb.ChannelHeader)
+	if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), header); err != nil {
+		return "", nil, err
+	}
+	if cb.HeaderType(header.GetType()) != cb.HeaderType_ENDORSER_TRANSACTION {
+		return header.GetTxId(), nil, nil
+	}
+
+	tx := new(pb.Transaction)
+	if err := proto.Unmarshal(payload.GetData(), tx); err != nil {
+		return "", nil, err
+	}
+	for _, action := range tx.GetActions() {
+		actionPayload := new(pb.ChaincodeActionPayload)
+		if err := proto.Unmarshal(action.GetPayload(), actionPayload); err != nil {
+			return "", nil, err
+		}
+		responsePayload := new(pb.ProposalResponsePayload)
+		if err := proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload); err != nil {
+			return "", nil, err
+		}
+		ccAction := new(pb.ChaincodeAction)
+		if err := proto.Unmarshal(responsePayload.GetExtension(), ccAction); err != nil {
+			return "", nil, err
+		}
+		if len(ccAction.GetEvents()) == 0 {
+			continue
+		}
+		ccEvent := new(pb.ChaincodeEvent)
+		if err := proto.Unmarshal(ccAction.GetEvents(), ccEvent); err != nil {
+			return "", nil, err
+		}
+		return header.GetTxId(), ccEvent, nil
+	}
+	return header.GetTxId(), nil, nil
+}
@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// 构造带链码事件的背书交易
+func newEventTx(txId, chaincode, name string) []byte {
+	events, _ := proto.Marshal(&pb.ChaincodeEvent{ChaincodeId: chaincode, TxId: txId, EventName: name, Payload: []byte(`{}`)})
+	extension, _ := proto.Marshal(&pb.ChaincodeAction{Events: events})
+	response, _ := proto.Marshal(&pb.ProposalResponsePayload{Extension: extension})
+	action, _ := proto.Marshal(&pb.ChaincodeActionPayload{Action: &pb.ChaincodeEndorsedAction{ProposalResponsePayload: response}})
+	tx, _ := proto.Marshal(&pb.Transaction{Actions: []*pb.TransactionAction{{Payload: action}}})
+	header, _ := proto.Marshal(&cb.ChannelHeader{Type: int32(cb.HeaderType_ENDORSER_TRANSACTION), TxId: txId})
+	payload, _ := proto.Marshal(&cb.Payload{Header: &cb.Header{ChannelHeader: header}, Data: tx})
+	envelope, _ := proto.Marshal(&cb.Envelope{Payload: payload})
+	return envelope
+}
+
+// 只取出本链码有效交易的事件
+func TestParseBlock(t *testing.T) {
+	block := &cb.Block{
+		Header: &cb.BlockHeader{Number: 7},
+		Data: &cb.BlockData{Data: [][]byte{
+			newEventTx("tx1", "mychaincode", "OrderCreated"),
+			newEventTx("tx2", "mychaincode", "OrderStatusChanged"),
+			newEventTx("tx3", "othercc", "OrderCreated"),
+			newEventTx("tx4", "mychaincode", "SettlementCompleted"),
+		}},
+		Metadata: &cb.BlockMetadata{Metadata: [][]byte{{}, {}, {
+			byte(pb.TxValidationCode_VALID),
+			byte(pb.TxValidationCode_MVCC_READ_CONFLICT),
+			byte(pb.TxValidationCode_VALID),
+			byte(pb.TxValidationCode_VALID),
+		}}},
+	}
+	result, err := parseBlock(block, "mychaincode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Number != 7 || len(result.Events) != 2 {
+		t.Fatalf("expected 2 events in block 7, got %+v", result)
+	}
+	if result.Events[0].TxId != "tx1" || result.Events[1].Name != "SettlementCompleted" || result.Events[1].Block != 7 {
+		t.Fatalf("unexpected events %+v %+v", result.Events[0], result.Events[1])
+	}
+}
+
+// 本地模拟账本每个提交的交易一个区块，订阅后先推送已有区块，再推送新提交的区块
+func TestMockLedgerBlocks(t *testing.T) {
+	l := newMockLedger(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	args := func(id string) [][]byte {
+		return [][]byte{[]byte(id), []byte("carrier"), []byte("100"), []byte("carrier")}
+	}
+
+	resp, err := l.Execute(ctx, nil, "createAccount", args("c1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = l.Query(ctx, nil, "createAccount", args("c2"))
+
+	blocks, err := l.Blocks(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := <-blocks
+	if block.Number != 1 || len(block.Events) != 1 || block.Events[0].Name != "AccountCreated" || block.Events[0].TxId != string(resp.TransactionID) {
+		t.Fatalf("unexpected block %+v", block)
+	}
+
+	for i := 3; i <= 4; i++ {
+		if _, err := l.Execute(ctx, nil, "createAccount", args(fmt.Sprintf("c%d", i))); err != nil {
+			t.Fatal(err)
+		}
+		if block := <-blocks; block.Number != uint64(i-1) || len(block.Events) != 1 {
+			t.Fatalf("unexpected block %+v", block)
+		}
+	}
+
+	cancel()
+	if _, ok := <-blocks; ok {
+		t.Fatal("blocks should be closed after cancel")
+	}
+}
@@ -0,0 +1,42 @@
+package blockchain
+
+import (
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+)
+
+// 调用链码的身份，可以是配置文件中组织的用户，也可以是从钱包中加载的已登记身份
+type Identity struct {
+	Org    string              // 组织名称
+	User   string              // 用户名，Signer为空时使用配置文件中该用户的证书
+	Signer msp.SigningIdentity // 已登记用户的签名身份
+
+	AccountId string // 链上账户ID，本地模拟账本据此生成带账户属性的调用者证书
+}
+
+// 默认身份，即配置文件中组织的管理员，用于后台任务和未登录的请求
+func DefaultIdentity() *Identity {
+	return &Identity{Org: Current.Org, User: Current.User}
+}
+
+// 转换为sdk的上下文选项，身份为空时使用默认身份
+func (id *Identity) options() []fabsdk.ContextOption {
+	if id == nil {
+		id = DefaultIdentity()
+	}
+	if id.Signer != nil {
+		return []fabsdk.ContextOption{fabsdk.WithOrg(id.Org), fabsdk.WithIdentity(id.Signer)}
+	}
+	return []fabsdk.ContextOption{fabsdk.WithOrg(id.Org), fabsdk.WithUser(id.User)}
+}
+
+// 身份的用户名，用于日志
+func (id *Identity) String() string {
+	if id == nil {
+		return DefaultIdentity().String()
+	}
+	if id.Signer != nil {
+		return id.Org + "/" + id.Signer.Identifier().ID
+	}
+	return id.Org + "/" + id.User
+}
@@ -0,0 +1,16 @@
+package blockchain
+
+import "testing"
+
+// 没有指定身份时使用配置文件中的默认用户
+func TestDefaultIdentity(t *testing.T) {
+	var id *Identity
+	if id.String() != Current.Org+"/"+Current.User || len(id.options()) != 2 {
+		t.Fatalf("unexpected default identity %s", id)
+	}
+
+	id = &Identity{Org: "org2", User: "User1"}
+	if id.String() != "org2/User1" {
+		t.Fatalf("unexpected identity %s", id)
+	}
+}
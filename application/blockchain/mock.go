@@ -0,0 +1,294 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"gdzce.cn/perishable-food/chaincode/perishable-food/perishablefood"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+var MockMspId = "Organization1MSP" // 本地模拟账本中调用者的MSP ID
+
+// fabric-ca存放证书属性的扩展
+var attrsOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// 在进程内以MockStub运行链码的账本，数据只保存在内存中，用于开发和测试
+type MockLedger struct {
+	MspId string
+
+	mutex    sync.Mutex
+	stub     *shimtest.MockStub
+	cc       shim.Chaincode
+	creators map[string][]byte // 按账户缓存的调用者证书
+	blocks   []*Block          // 已提交的区块，每个交易一个区块，区块0为链码初始化
+	notify   chan struct{}     // 提交新区块时关闭并替换，通知订阅者
+}
+
+// 创建账本并以chaincode为名初始化链码
+func NewMockLedger(chaincode string) (*MockLedger, error) {
+	cc := new(perishablefood.PerishableFood)
+	l := &MockLedger{
+		MspId:    MockMspId,
+		stub:     shimtest.NewMockStub(chaincode, cc),
+		cc:       cc,
+		creators: make(map[string][]byte),
+		blocks:   []*Block{{Number: 0}},
+		notify:   make(chan struct{}),
+	}
+
+	// 与Fabric账本一致，由组织管理员初始化链码
+	creator, err := l.creator(nil)
+	if err != nil {
+		return nil, err
+	}
+	l.stub.Creator = creator
+	resp := l.stub.MockInit(newTxId(), [][]byte{[]byte("init")})
+	if resp.Status >= shim.ERRORTHRESHOLD {
+		return nil, fmt.Errorf("chaincode init error: %s", resp.Message)
+	}
+	return l, nil
+}
+
+// 从区块from开始推送已提交和之后提交的区块
+func (l *MockLedger) Blocks(ctx context.Context, from uint64) (<-chan *Block, error) {
+	blocks := make(chan *Block)
+	go func() {
+		defer close(blocks)
+		next := from
+		for {
+			l.mutex.Lock()
+			var pending []*Block
+			if next < uint64(len(l.blocks)) {
+				pending = l.blocks[next:]
+			}
+			notify := l.notify
+			l.mutex.Unlock()
+
+			for _, block := range pending {
+				select {
+				case blocks <- block:
+					next++
+				case <-ctx.Done():
+					return
+				}
+			}
+			if len(pending) != 0 {
+				continue
+			}
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return blocks, nil
+}
+
+// 提交区块，调用时已持有锁
+func (l *MockLedger) commitBlock(txId string, event *pb.ChaincodeEvent) {
+	block := &Block{Number: uint64(len(l.blocks))}
+	if event != nil {
+		block.Events = []*Event{{Block: block.Number, TxId: txId, Name: event.EventName, Payload: event.Payload}}
+	}
+	l.blocks = append(l.blocks, block)
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+func (l *MockLedger) Execute(ctx context.Context, id *Identity, fcn string, args [][]byte) (channel.Response, error) {
+	return l.invoke(ctx, id, fcn, args, true)
+}
+
+func (l *MockLedger) Query(ctx context.Context, id *Identity, fcn string, args [][]byte) (channel.Response, error) {
+	return l.invoke(ctx, id, fcn, args, false)
+}
+
+// 调用链码，成功且需要提交时才写入账本，与Fabric一样交易内读不到自己的写入
+func (l *MockLedger) invoke(ctx context.Context, id *Identity, fcn string, args [][]byte, commit bool) (channel.Response, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// 等待锁期间请求已取消时不再调用
+	if ctx != nil {
+		if err := ctx.Err(); err != nil {
+			return channel.Response{}, err
+		}
+	}
+
+	creator, err := l.creator(id)
+	if err != nil {
+		return channel.Response{}, err
+	}
+
+	txId := newTxId()
+	stub := &mockCallStub{
+		MockStub: l.stub,
+		args:     append([][]byte{[]byte(fcn)}, args...),
+		creator:  creator,
+	}
+	l.stub.MockTransactionStart(txId)
+	resp := l.cc.Invoke(stub)
+	if resp.Status < shim.ERRORTHRESHOLD && commit {
+		for _, w := range stub.writes {
+			if w.value == nil {
+				err = l.stub.DelState(w.key)
+			} else {
+				err = l.stub.PutState(w.key, w.value)
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			l.commitBlock(txId, stub.event)
+		}
+	}
+	l.stub.MockTransactionEnd(txId)
+
+	if err != nil {
+		return channel.Response{}, err
+	}
+	if resp.Status >= shim.ERRORTHRESHOLD {
+		// 与sdk返回的链码错误一致，可由ChaincodeStatus取出状态码
+		return channel.Response{}, status.New(status.ChaincodeStatus, resp.Status, resp.Message, nil)
+	}
+	return channel.Response{
+		Payload:         resp.Payload,
+		ChaincodeStatus: resp.Status,
+		TransactionID:   fab.TransactionID(txId),
+	}, nil
+}
+
+// 调用者证书，已登记的身份使用其证书，否则生成带账户属性的自签名证书
+// 没有账户的身份即默认身份，与配置文件中的组织管理员一致，证书的OU为admin
+func (l *MockLedger) creator(id *Identity) ([]byte, error) {
+	if id != nil && id.Signer != nil {
+		return id.Signer.Serialize()
+	}
+
+	accountId := ""
+	if id != nil {
+		accountId = id.AccountId
+	}
+	if creator, ok := l.creators[accountId]; ok {
+		return creator, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: Current.User, OrganizationalUnit: []string{"admin"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+	}
+	if accountId != "" {
+		attrs, _ := json.Marshal(map[string]map[string]string{"attrs": {"account.id": accountId}})
+		template.Subject = pkix.Name{CommonName: accountId}
+		template.ExtraExtensions = []pkix.Extension{{Id: attrsOID, Value: attrs}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   l.MspId,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.creators[accountId] = creator
+	return creator, nil
+}
+
+// 交易中的写入，value为空表示删除
+type mockWrite struct {
+	key   string
+	value []byte
+}
+
+// 单次调用的stub，提供调用参数和调用者，并暂存写入
+type mockCallStub struct {
+	*shimtest.MockStub
+	args    [][]byte
+	creator []byte
+	writes  []mockWrite
+	event   *pb.ChaincodeEvent // 交易的链码事件，与Fabric一样只保留最后一个
+}
+
+func (s *mockCallStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *mockCallStub) GetStringArgs() []string {
+	strargs := make([]string, 0, len(s.args))
+	for _, barg := range s.args {
+		strargs = append(strargs, string(barg))
+	}
+	return strargs
+}
+
+func (s *mockCallStub) GetFunctionAndParameters() (string, []string) {
+	allargs := s.GetStringArgs()
+	if len(allargs) == 0 {
+		return "", []string{}
+	}
+	return allargs[0], allargs[1:]
+}
+
+func (s *mockCallStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *mockCallStub) PutState(key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	s.writes = append(s.writes, mockWrite{key: key, value: value})
+	return nil
+}
+
+func (s *mockCallStub) DelState(key string) error {
+	s.writes = append(s.writes, mockWrite{key: key})
+	return nil
+}
+
+// 记录事件，提交时写入区块，不写入MockStub容量有限的事件通道
+func (s *mockCallStub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return fmt.Errorf("event name can not be empty string")
+	}
+	s.event = &pb.ChaincodeEvent{EventName: name, Payload: payload}
+	return nil
+}
+
+// 生成与Fabric格式相同的交易ID
+func newTxId() string {
+	nonce := make([]byte, 32)
+	_, _ = rand.Read(nonce)
+	return hex.EncodeToString(nonce)
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/multi"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+func newMockLedger(t *testing.T) *MockLedger {
+	l, err := NewMockLedger("mychaincode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func accountIdentity(accountId string) *Identity {
+	id := DefaultIdentity()
+	id.AccountId = accountId
+	return id
+}
+
+// 初始化后能查询到链码Init写入的账户
+func TestMockLedger1(t *testing.T) {
+	ctx := context.Background()
+	l := newMockLedger(t)
+	resp, err := l.Query(ctx, nil, "queryAccount", [][]byte{[]byte("all")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var accounts []map[string]interface{}
+	if err := json.Unmarshal(resp.Payload, &accounts); err != nil || len(accounts) != 4 {
+		t.Fatalf("unexpected accounts %s, %v", resp.Payload, err)
+	}
+}
+
+// 以账户身份提交交易，交易ID与Fabric格式相同，调用者与账户不符时链码拒绝
+func TestMockLedger2(t *testing.T) {
+	ctx := context.Background()
+	l := newMockLedger(t)
+	args := [][]byte{[]byte("apple"), []byte("c1"), []byte("origin"), []byte("0"), []byte("10"), []byte("5"), []byte("1")}
+
+	if _, err := l.Execute(ctx, accountIdentity("3"), "createCommodity", args); err == nil || !strings.Contains(err.Error(), "caller is not account 1") {
+		t.Fatalf("expected caller error, got %v", err)
+	}
+
+	resp, err := l.Execute(ctx, accountIdentity("1"), "createCommodity", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ChaincodeStatus != 200 || len(resp.TransactionID) != 64 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	resp, err = l.Query(ctx, nil, "queryCommodityList", [][]byte{[]byte("c1")})
+	if err != nil || !strings.Contains(string(resp.Payload), `"owner":"1"`) {
+		t.Fatalf("unexpected commodity %s, %v", resp.Payload, err)
+	}
+}
+
+// 查询和失败的交易不写入账本
+func TestMockLedger3(t *testing.T) {
+	ctx := context.Background()
+	l := newMockLedger(t)
+	args := [][]byte{[]byte("c1"), []byte("carrier"), []byte("100"), []byte("carrier")}
+
+	if _, err := l.Query(ctx, nil, "createAccount", args); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := l.Query(ctx, nil, "queryAccount", [][]byte{[]byte("c1")}); strings.Contains(string(resp.Payload), `"c1"`) {
+		t.Fatal("query should not commit")
+	}
+
+	if _, err := l.Execute(ctx, nil, "createAccount", args[:3]); err == nil {
+		t.Fatal("expected error for missing role")
+	}
+	if _, err := l.Execute(ctx, nil, "createAccount", args); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := l.Query(ctx, nil, "queryAccount", [][]byte{[]byte("c1")}); err != nil || !strings.Contains(string(resp.Payload), `"c1"`) {
+		t.Fatalf("unexpected account %s, %v", resp.Payload, err)
+	}
+}
+
+// 链码每个交易都发出事件，长时间运行不会因MockStub的事件通道写满而阻塞
+func TestMockLedger4(t *testing.T) {
+	ctx := context.Background()
+	l := newMockLedger(t)
+	for i := 0; i < 150; i++ {
+		args := [][]byte{[]byte(fmt.Sprintf("c%d", i)), []byte("carrier"), []byte("100"), []byte("carrier")}
+		if _, err := l.Execute(ctx, nil, "createAccount", args); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 链码状态码在本地模拟账本和多个背书节点的错误中都能取出
+func TestChaincodeStatus(t *testing.T) {
+	closed := status.New(status.ChaincodeStatus, StatusOrderClosed, "order is not processing", nil)
+	endorser := status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection failed", nil)
+	for _, c := range []struct {
+		err  error
+		code int32
+		ok   bool
+	}{
+		{nil, 0, false},
+		{fmt.Errorf("order is not processing"), 0, false},
+		{closed, StatusOrderClosed, true},
+		{multi.Errors{endorser, closed}, StatusOrderClosed, true},
+	} {
+		if code, ok := ChaincodeStatus(c.err); code != c.code || ok != c.ok {
+			t.Fatalf("%v: expected %d %v, got %d %v", c.err, c.code, c.ok, code, ok)
+		}
+	}
+
+}
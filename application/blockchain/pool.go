@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+)
+
+// 通道客户端池，按(通道, 组织, 用户)缓存客户端，避免每次调用都重新创建通道上下文，并发安全
+type ClientPool struct {
+	SDK *fabsdk.FabricSDK
+
+	mutex   sync.Mutex
+	clients map[clientKey]*pooledClient
+	create  func(channelId string, id *Identity) (*channel.Client, error) // 创建客户端，测试时可替换
+}
+
+// 客户端缓存的键
+type clientKey struct {
+	Channel string
+	Org     string
+	User    string
+}
+
+// 缓存的客户端，同一用户重新登记后证书变化，需要重新创建客户端
+type pooledClient struct {
+	cert   []byte
+	client *channel.Client
+}
+
+func NewClientPool(sdk *fabsdk.FabricSDK) *ClientPool {
+	p := &ClientPool{SDK: sdk, clients: make(map[clientKey]*pooledClient)}
+	p.create = func(channelId string, id *Identity) (*channel.Client, error) {
+		return channel.New(p.SDK.ChannelContext(channelId, id.options()...))
+	}
+	return p
+}
+
+// 获取以id的身份访问通道的客户端，不存在时创建
+func (p *ClientPool) Get(channelId string, id *Identity) (*channel.Client, error) {
+	if id == nil {
+		id = DefaultIdentity()
+	}
+	key, cert := poolKey(channelId, id)
+
+	p.mutex.Lock()
+	cached, ok := p.clients[key]
+	p.mutex.Unlock()
+	if ok && bytes.Equal(cached.cert, cert) {
+		return cached.client, nil
+	}
+
+	// 创建客户端时不持有锁，并发创建同一客户端时保留先写入的
+	cli, err := p.create(channelId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if cached, ok := p.clients[key]; ok && bytes.Equal(cached.cert, cert) {
+		return cached.client, nil
+	}
+	p.clients[key] = &pooledClient{cert: cert, client: cli}
+	return cli, nil
+}
+
+// 移除通道中某个身份的客户端，如用户证书被吊销后
+func (p *ClientPool) Remove(channelId string, id *Identity) {
+	if id == nil {
+		id = DefaultIdentity()
+	}
+	key, _ := poolKey(channelId, id)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.clients, key)
+}
+
+// 缓存的客户端数量
+func (p *ClientPool) Len() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.clients)
+}
+
+// 身份在池中的键，已登记身份同时返回证书用于判断是否重新登记过
+func poolKey(channelId string, id *Identity) (clientKey, []byte) {
+	if id.Signer != nil {
+		return clientKey{Channel: channelId, Org: id.Org, User: id.Signer.Identifier().ID}, id.Signer.EnrollmentCertificate()
+	}
+	return clientKey{Channel: channelId, Org: id.Org, User: id.User}, nil
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+)
+
+// 同一通道、组织和用户并发获取时复用同一个客户端，不同用户各自一个，移除后重新创建
+func TestClientPool(t *testing.T) {
+	pool := NewClientPool(nil)
+	var created int32
+	pool.create = func(channelId string, id *Identity) (*channel.Client, error) {
+		atomic.AddInt32(&created, 1)
+		return &channel.Client{}, nil
+	}
+
+	var wg sync.WaitGroup
+	clients := make([]*channel.Client, 10)
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cli, err := pool.Get("mychannel", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			clients[i] = cli
+		}(i)
+	}
+	wg.Wait()
+	for _, cli := range clients {
+		if cli == nil || cli != clients[0] {
+			t.Fatal("clients of the same identity are not reused")
+		}
+	}
+
+	cli, err := pool.Get("mychannel", &Identity{Org: Current.Org, User: "User1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cli == clients[0] || pool.Len() != 2 {
+		t.Fatalf("expected 2 clients, got %d", pool.Len())
+	}
+
+	pool.Remove("mychannel", nil)
+	if pool.Len() != 1 {
+		t.Fatalf("expected 1 client after remove, got %d", pool.Len())
+	}
+	before := atomic.LoadInt32(&created)
+	if cli, _ := pool.Get("mychannel", nil); cli == clients[0] || atomic.LoadInt32(&created) != before+1 {
+		t.Fatal("removed client should be created again")
+	}
+}
+
+// 请求已取消时本地模拟账本不再调用链码
+func TestMockLedgerCanceled(t *testing.T) {
+	l := newMockLedger(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.Execute(ctx, nil, "createAccount", [][]byte{[]byte("c1"), []byte("c1"), []byte("buyer"), []byte("addr")}); err != context.Canceled {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	if resp, err := l.Query(context.Background(), nil, "queryAccount", [][]byte{[]byte("c1")}); err != nil || len(resp.Payload) > 2 {
+		t.Fatalf("canceled transaction was committed: %s, %v", resp.Payload, err)
+	}
+}
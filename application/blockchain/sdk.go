@@ -1,103 +1,159 @@
 package blockchain
 
 import (
+	"context"
+	"fmt"
+	"time"
+
+	"gdzce.cn/perishable-food/chaincode/perishable-food/perishablefood"
 	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
-	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	sdkconfig "github.com/hyperledger/fabric-sdk-go/pkg/core/config"
 	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
 )
 
-// sdk相关配置
+// 账本后端
+const (
+	BackendFabric = "fabric" // 通过sdk访问Fabric网络
+	BackendMock   = "mock"   // 在进程内以MockStub运行链码，不需要Docker
+)
+
+// 账本，以指定身份调用链码，ctx取消或超时后放弃调用
+type Ledger interface {
+	// 对区块链增删改的操作（调用了链码的invoke），提交交易
+	Execute(ctx context.Context, id *Identity, fcn string, args [][]byte) (channel.Response, error)
+	// 对区块链查询的操作，不提交交易
+	Query(ctx context.Context, id *Identity, fcn string, args [][]byte) (channel.Response, error)
+}
+
+// 由Init按配置设置，之后只读
 var (
-	SDK           *fabsdk.FabricSDK // Fabric提供的SDK
-	ChannelName   = "mychannel"     // 通道名称
-	ChaincodeName = "mychaincode"   // 链码名称
-	Org           = "org1"          // 组织名称
-	User          = "Admin"         // 用户
-	ConfigPath    = "./config.yaml" // 配置文件路径
+	SDK           *fabsdk.FabricSDK // Fabric提供的SDK，本地模拟账本时为空
+	Current       = DefaultConfig() // 当前使用的账本配置
+	DefaultLedger Ledger            // 默认合约的账本
+	ledgers       map[string]Ledger // 按合约名称索引的账本
 )
 
-// sdk初始化
-func Init() {
-	var err error
-	// 通过配置文件初始化SDK
-	SDK, err = fabsdk.New(config.FromFile(ConfigPath))
+// 按配置的后端初始化所有合约的账本
+func Init(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	sdk, all, err := NewLedgers(config)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	SDK, Current, ledgers = sdk, config, all
+	DefaultLedger = all[config.DefaultContract().Name]
+	return nil
 }
 
-// 区块链交互
-func ChannelExecute(fcn string, args [][]byte) (channel.Response, error) {
-	var resp channel.Response
-	switch fcn {
-	case "createCommodity":
-		if len(args) == 7 {
-			resp.ChaincodeStatus = 200
-		} else {
-			resp.ChaincodeStatus = 500
+// 按配置创建每个合约的账本，Fabric后端的合约共用同一个SDK和客户端池
+func NewLedgers(config Config) (*fabsdk.FabricSDK, map[string]Ledger, error) {
+	all := make(map[string]Ledger, len(config.Contracts))
+	switch config.Backend {
+	case BackendMock:
+		// 每个合约一个独立的账本，相当于不同的通道
+		for _, contract := range config.Contracts {
+			ledger, err := NewMockLedger(contract.Chaincode)
+			if err != nil {
+				return nil, nil, err
+			}
+			all[contract.Name] = ledger
 		}
-		return resp, nil
-	case "createOrder":
-		if len(args) >= 7 {
-			resp.ChaincodeStatus = 200
-			resp.TransactionID = "123456789"
-		} else {
-			resp.ChaincodeStatus = 500
+		return nil, all, nil
+	case "", BackendFabric:
+		sdk, err := fabsdk.New(sdkconfig.FromFile(config.SDKConfig))
+		if err != nil {
+			return nil, nil, err
 		}
-		return resp, nil
-	case "updateOrderStatus":
-		if len(args) >= 2 {
-			resp.ChaincodeStatus = 200
-		} else {
-			resp.ChaincodeStatus = 500
+		clients := NewClientPool(sdk)
+		for _, contract := range config.Contracts {
+			all[contract.Name] = NewFabricLedger(clients, contract, config)
 		}
-		return resp, nil
+		return sdk, all, nil
 	default:
-		return resp, nil
+		return nil, nil, fmt.Errorf("unknown ledger backend %s", config.Backend)
 	}
 }
 
-// 区块链查询
-func ChannelQuery(fcn string, args [][]byte) (channel.Response, error) {
-	var resp channel.Response
-	switch fcn {
-	case "queryCommodityList":
-		resp.ChaincodeStatus = 200
-		return resp, nil
-	case "queryOrderList":
-		if len(args) == 1 || len(args) == 0 {
-			resp.ChaincodeStatus = 200
-		} else {
-			resp.ChaincodeStatus = 500
-		}
-		return resp, nil
-	case "queryAccount":
-		if len(args) == 1 {
-			resp.ChaincodeStatus = 200
-		} else {
-			resp.ChaincodeStatus = 500
+// 按名称获取合约的账本
+func Contract(name string) (Ledger, error) {
+	ledger, ok := ledgers[name]
+	if !ok {
+		return nil, fmt.Errorf("contract %s not exists", name)
+	}
+	return ledger, nil
+}
+
+// 区块链交互，ctx通常为http请求的上下文，客户端断开后不再等待
+func ChannelExecute(ctx context.Context, id *Identity, fcn string, args [][]byte) (channel.Response, error) {
+	return DefaultLedger.Execute(ctx, id, fcn, args)
+}
+
+// 区块链查询，以id的身份查询
+func ChannelQuery(ctx context.Context, id *Identity, fcn string, args [][]byte) (channel.Response, error) {
+	return DefaultLedger.Query(ctx, id, fcn, args)
+}
+
+// 链码返回的状态码：订单不在运送中，不能记录温度
+const StatusOrderClosed = perishablefood.StatusOrderClosed
+
+// 取出链码返回的错误状态码，多个背书节点都返回错误时取第一个链码状态
+func ChaincodeStatus(err error) (int32, bool) {
+	s, ok := status.FromError(err)
+	if err == nil || !ok {
+		return 0, false
+	}
+	if s.Group == status.ChaincodeStatus {
+		return s.Code, true
+	}
+	for _, detail := range s.Details {
+		if detailErr, ok := detail.(error); ok {
+			if code, ok := ChaincodeStatus(detailErr); ok {
+				return code, true
+			}
 		}
-		return resp, nil
-	default:
-		return resp, nil
+	}
+	return 0, false
+}
+
+// 通过sdk访问的Fabric网络中的一个合约
+type FabricLedger struct {
+	Channel   string        // 通道名称
+	Chaincode string        // 链码名称
+	Endorsers []string      // 背书节点，为空时通过服务发现选择
+	Timeout   time.Duration // 单次调用的超时时间
+	Retry     retry.Opts    // 临时错误的重试策略
+	Clients   *ClientPool   // 按身份复用的通道客户端
+}
+
+func NewFabricLedger(clients *ClientPool, contract ContractConfig, config Config) *FabricLedger {
+	return &FabricLedger{
+		Channel:   contract.Channel,
+		Chaincode: contract.Chaincode,
+		Endorsers: contract.Endorsers,
+		Timeout:   time.Duration(config.Timeout),
+		Retry:     config.Retry.opts(),
+		Clients:   clients,
 	}
 }
 
-// 区块链交互
-func ChannelExecute1(fcn string, args [][]byte) (channel.Response, error) {
-	// 创建客户端，表明在通道的身份
-	ctx := SDK.ChannelContext(ChannelName, fabsdk.WithOrg(Org), fabsdk.WithUser(User))
-	cli, err := channel.New(ctx)
+func (l *FabricLedger) Execute(ctx context.Context, id *Identity, fcn string, args [][]byte) (channel.Response, error) {
+	cli, err := l.Clients.Get(l.Channel, id)
 	if err != nil {
 		return channel.Response{}, err
 	}
+	ctx, cancel := l.withTimeout(ctx)
+	defer cancel()
 
 	// 对区块链增删改的操作（调用了链码的invoke）
 	resp, err := cli.Execute(channel.Request{
-		ChaincodeID: ChaincodeName,
+		ChaincodeID: l.Chaincode,
 		Fcn:         fcn,
 		Args:        args,
-	}, channel.WithTargetEndpoints("node1.organization1.gdzce.cn", "node1.organization2.gdzce.cn", "node1.organization3.gdzce.cn"))
+	}, l.options(ctx)...)
 
 	if err != nil {
 		return channel.Response{}, err
@@ -107,19 +163,41 @@ func ChannelExecute1(fcn string, args [][]byte) (channel.Response, error) {
 	return resp, nil
 }
 
-// 区块链查询
-func ChannelQuery1(fcn string, args [][]byte) (channel.Response, error) {
-	// 同上
-	ctx := SDK.ChannelContext(ChannelName, fabsdk.WithOrg(Org), fabsdk.WithUser(User))
-	cli, err := channel.New(ctx)
+func (l *FabricLedger) Query(ctx context.Context, id *Identity, fcn string, args [][]byte) (channel.Response, error) {
+	cli, err := l.Clients.Get(l.Channel, id)
 	if err != nil {
 		return channel.Response{}, err
 	}
+	ctx, cancel := l.withTimeout(ctx)
+	defer cancel()
 
 	// 对区块链查询的操作（调用了链码的invoke），将结果返回
 	return cli.Query(channel.Request{
-		ChaincodeID: ChaincodeName,
+		ChaincodeID: l.Chaincode,
 		Fcn:         fcn,
 		Args:        args,
-	}, channel.WithTargetEndpoints("node1.organization1.gdzce.cn", "node1.organization2.gdzce.cn", "node1.organization3.gdzce.cn"))
+	}, l.options(ctx)...)
+}
+
+// 为调用设置超时，未配置时只受ctx限制
+func (l *FabricLedger) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if l.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, l.Timeout)
+}
+
+// 调用链码的请求选项：上下文、重试策略和背书节点，未配置背书节点时由sdk通过服务发现选择
+func (l *FabricLedger) options(ctx context.Context) []channel.RequestOption {
+	opts := []channel.RequestOption{
+		channel.WithParentContext(ctx),
+		channel.WithRetry(l.Retry),
+	}
+	if len(l.Endorsers) != 0 {
+		opts = append(opts, channel.WithTargetEndpoints(l.Endorsers...))
+	}
+	return opts
 }
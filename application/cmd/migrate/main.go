@@ -0,0 +1,55 @@
+// 在交易记录存储之间迁移订单id与交易id的对应关系，默认从JSON文件迁移到bbolt数据库
+//
+//	go run ./cmd/migrate -from json -src transactionRecord.json -to bolt -dst ./data/transactionRecord.db
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gdzce.cn/perishable-food/application/repository"
+)
+
+func main() {
+	from := flag.String("from", repository.StoreJSON, "源存储类型，json或bolt")
+	src := flag.String("src", "transactionRecord.json", "源存储路径")
+	to := flag.String("to", repository.StoreBolt, "目标存储类型，json或bolt")
+	dst := flag.String("dst", repository.BoltPath, "目标存储路径")
+	flag.Parse()
+
+	if err := migrate(*from, *src, *to, *dst); err != nil {
+		fmt.Fprintln(os.Stderr, "迁移失败：", err)
+		os.Exit(1)
+	}
+}
+
+func migrate(from, src, to, dst string) error {
+	if src == "" || dst == "" {
+		return fmt.Errorf("src and dst are required")
+	}
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	srcStore, err := repository.OpenStore(from, src)
+	if err != nil {
+		return err
+	}
+	defer srcStore.Close()
+
+	// 目标为JSON文件时，已有内容会与源记录合并
+	dstStore, err := repository.OpenStore(to, dst)
+	if err != nil {
+		return err
+	}
+	n, err := repository.Migrate(dstStore, srcStore)
+	if err != nil {
+		_ = dstStore.Close()
+		return err
+	}
+	if err := dstStore.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("已将%d条记录从%s迁移到%s，下一个同步的区块为%d\n", n, src, dst, srcStore.NextBlock())
+	return nil
+}
@@ -2,6 +2,8 @@ package controller
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
 
 	bc "gdzce.cn/perishable-food/application/blockchain"
@@ -22,10 +24,11 @@ func AccountList(ctx *gin.Context) {
 		return
 	}
 
-	// 调用链码的queryAccount，查询账户列表
-	resp, err := bc.ChannelQuery("queryAccount", [][]byte{
+	// 调用链码的queryAccount，查询账户列表，链码按调用者证书确定查询人，非监管方只能查询自己的账户
+	args := [][]byte{
 		[]byte(req.AccountId),
-	})
+	}
+	resp, err := bc.ChannelQuery(ctx.Request.Context(), identity(ctx), "queryAccount", args)
 	if err != nil {
 		ctx.String(http.StatusInternalServerError, err.Error())
 		return
@@ -34,3 +37,97 @@ func AccountList(ctx *gin.Context) {
 	// 将结果返回
 	ctx.String(http.StatusOK, bytes.NewBuffer(resp.Payload).String())
 }
+
+// 创建账户请求体
+type createAccountRequest struct {
+	Id      string  `json:"id" form:"id" binding:"required"`                                            // 账号ID
+	Name    string  `json:"name" form:"name" binding:"required"`                                        // 账号名
+	Balance float64 `json:"balance" form:"balance" binding:"min=0"`                                     // 初始余额
+	Role    string  `json:"role" form:"role" binding:"required,oneof=supplier carrier buyer regulator"` // 角色
+}
+
+// 创建账户
+func CreateAccount(ctx *gin.Context) {
+	// 解析请求体
+	req := new(createAccountRequest)
+	if err := ctx.ShouldBind(req); err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	// 打印请求体
+	fmt.Println("请求参数：")
+	marshal, _ := json.Marshal(req)
+	fmt.Println(string(marshal))
+
+	// 调用链码的createAccount函数
+	resp, err := bc.ChannelExecute(ctx.Request.Context(), identity(ctx), "createAccount", [][]byte{
+		[]byte(req.Id),
+		[]byte(req.Name),
+		[]byte(fmt.Sprintf("%v", req.Balance)),
+		[]byte(req.Role),
+	})
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// http返回
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 更新账户请求体
+type updateAccountRequest struct {
+	Id   string `json:"id" form:"id" binding:"required"`     // 账号ID
+	Name string `json:"name" form:"name" binding:"required"` // 账号名
+}
+
+// 更新账户
+func UpdateAccount(ctx *gin.Context) {
+	// 解析请求体
+	req := new(updateAccountRequest)
+	if err := ctx.ShouldBind(req); err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	// 调用链码的updateAccount函数
+	resp, err := bc.ChannelExecute(ctx.Request.Context(), identity(ctx), "updateAccount", [][]byte{
+		[]byte(req.Id),
+		[]byte(req.Name),
+	})
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// http返回
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// 注销账户请求体
+type deactivateAccountRequest struct {
+	Id string `json:"id" form:"id" binding:"required"` // 账号ID
+}
+
+// 注销账户
+func DeactivateAccount(ctx *gin.Context) {
+	// 解析请求体
+	req := new(deactivateAccountRequest)
+	if err := ctx.ShouldBind(req); err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	// 调用链码的deactivateAccount函数
+	resp, err := bc.ChannelExecute(ctx.Request.Context(), identity(ctx), "deactivateAccount", [][]byte{
+		[]byte(req.Id),
+	})
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// http返回
+	ctx.JSON(http.StatusOK, resp)
+}
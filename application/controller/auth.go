@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"gdzce.cn/perishable-food/application/auth"
+	bc "gdzce.cn/perishable-food/application/blockchain"
+	"gdzce.cn/perishable-food/application/wallet"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	Tokens        *auth.Signer                  // 签发登录令牌
+	AdminUser     = "admin"                     // 应用程序管理员的用户名
+	AdminPassword = os.Getenv("ADMIN_PASSWORD") // 管理员口令，为空时不能以管理员登录，本地模拟账本中账户也以此口令登录
+)
+
+// 登录请求体
+type loginRequest struct {
+	User     string `json:"user" form:"user" binding:"required"`         // 用户名
+	Password string `json:"password" form:"password" binding:"required"` // 口令
+}
+
+// 登录，校验口令后签发令牌
+func Login(ctx *gin.Context) {
+	// 解析请求体
+	req := new(loginRequest)
+	if err := ctx.ShouldBind(req); err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	accountId, role, ok := authenticate(ctx.Request.Context(), req.User, req.Password)
+	if !ok {
+		ctx.String(http.StatusUnauthorized, "invalid user or password")
+		return
+	}
+
+	token, expiresAt, err := Tokens.Issue(req.User, accountId, role)
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// http返回
+	ctx.JSON(http.StatusOK, gin.H{
+		"token":     token,
+		"expiresAt": expiresAt.UnixNano() / 1e6,
+		"user":      req.User,
+		"accountId": accountId,
+		"role":      role,
+	})
+}
+
+// 校验用户名和口令，返回账户ID和角色
+func authenticate(ctx context.Context, user, password string) (string, string, bool) {
+	if user == AdminUser {
+		return "", auth.RoleAdmin, checkAdminPassword(password)
+	}
+	if bc.Current.Backend == bc.BackendMock {
+		return authenticateMockAccount(ctx, user, password)
+	}
+	if CA == nil {
+		return "", "", false
+	}
+
+	entry, err := CA.Wallet.Get(user)
+	if err != nil || entry.Revoked || !auth.CheckPassword(entry.PasswordHash, password) {
+		return "", "", false
+	}
+	return entry.AccountId, entry.Role, true
+}
+
+// 本地模拟账本没有CA，以账户ID为用户名、管理员口令登录，角色从账本中的账户读取，只用于开发和测试
+func authenticateMockAccount(ctx context.Context, accountId, password string) (string, string, bool) {
+	if !checkAdminPassword(password) {
+		return "", "", false
+	}
+	account, err := LoadAccount(ctx, accountId)
+	if err != nil || account == nil || account.Deactivated || account.Role == "" {
+		return "", "", false
+	}
+	return account.Id, account.Role, true
+}
+
+// 管理员口令是否正确，未设置口令时总是不正确
+func checkAdminPassword(password string) bool {
+	return AdminPassword != "" && subtle.ConstantTimeCompare([]byte(password), []byte(AdminPassword)) == 1
+}
+
+// 设置用户的登录口令
+func setPassword(user, password string) (*wallet.Entry, error) {
+	entry, err := CA.Wallet.Get(user)
+	if err != nil {
+		return nil, err
+	}
+	entry.PasswordHash, err = auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	return entry, CA.Wallet.Put(entry)
+}
@@ -12,11 +12,13 @@ import (
 
 // 商品请求体
 type commodityRequest struct {
-	Name     string  `json:"name" form:"name" binding:"required"`         // 商品名
-	Id       string  `json:"id" form:"id" binding:"required"`             // id
-	Location string  `json:"location" form:"location" binding:"required"` // 产地
-	Price    float64 `json:"price" form:"price" binding:"required"`       // 单价
-	OwnerId  string  `json:"owner" form:"owner" binding:"required"`       // 所有者
+	Name            string  `json:"name" form:"name" binding:"required"`                       // 商品名
+	Id              string  `json:"id" form:"id" binding:"required"`                           // id
+	Location        string  `json:"location" form:"location" binding:"required"`               // 产地
+	LowTemperature  string  `json:"lowTemperature" form:"lowTemperature" binding:"required"`   // 最低温
+	HighTemperature string  `json:"highTemperature" form:"highTemperature" binding:"required"` // 最高温
+	Price           float64 `json:"price" form:"price" binding:"required"`                     // 单价
+	OwnerId         string  `json:"owner" form:"owner" binding:"required"`                     // 所有者
 }
 
 // 创建商品
@@ -34,10 +36,12 @@ func CreateCommodity(ctx *gin.Context) {
 	fmt.Println(string(marshal))
 
 	// 将请求体参数转化为byte数组，发送给区块链，调用链码的createCommodity函数
-	resp, err := bc.ChannelExecute("createCommodity", [][]byte{
+	resp, err := bc.ChannelExecute(ctx.Request.Context(), identity(ctx), "createCommodity", [][]byte{
 		[]byte(req.Name),
 		[]byte(req.Id),
 		[]byte(req.Location),
+		[]byte(req.LowTemperature),
+		[]byte(req.HighTemperature),
 		[]byte(fmt.Sprintf("%v", req.Price)),
 		[]byte(req.OwnerId),
 	})
@@ -54,7 +58,7 @@ func CreateCommodity(ctx *gin.Context) {
 // 查询商品列表
 func CommodityList(ctx *gin.Context) {
 	// 向区块链发起query，调用链码的queryCommodityList函数
-	resp, err := bc.ChannelQuery("queryCommodityList", [][]byte{})
+	resp, err := bc.ChannelQuery(ctx.Request.Context(), identity(ctx), "queryCommodityList", [][]byte{})
 	if err != nil {
 		ctx.String(http.StatusInternalServerError, err.Error())
 		return
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"gdzce.cn/perishable-food/application/auth"
+	"gdzce.cn/perishable-food/application/events"
+	"gdzce.cn/perishable-food/application/lib"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	EventHub       *events.Hub        // 事件监听推送的消息，未启动监听时为空
+	EventHeartbeat = 30 * time.Second // 没有事件时发送注释保持连接
+)
+
+const (
+	eventStream           = "text/event-stream" // Server-Sent Events的内容类型
+	eventCommodityCreated = "CommodityCreated"  // 创建商品的链码事件，商品列表是公开的
+)
+
+// 链码事件中与账户有关的字段
+type eventParties struct {
+	OrderId   string `json:"orderId"`
+	AccountId string `json:"accountId"`
+	BuyerId   string `json:"buyer"`
+	SellerId  string `json:"seller"`
+	CarrierId string `json:"carrier"`
+}
+
+// 以Server-Sent Events推送区块和链码事件，type参数只订阅指定的事件，逗号分隔
+// 与订单列表一致，管理员和监管方接收所有事件，其他账户只接收自己参与的订单和自己账户的事件
+func Events(ctx *gin.Context) {
+	if EventHub == nil {
+		ctx.String(http.StatusServiceUnavailable, "event listener is not running")
+		return
+	}
+
+	claims := ctx.MustGet(ClaimsKey).(*auth.Claims)
+	sub := EventHub.Subscribe(splitList(ctx.Query("type"))...)
+	defer sub.Close()
+	heartbeat := time.NewTicker(EventHeartbeat)
+	defer heartbeat.Stop()
+
+	// 立即返回响应头，客户端不必等到第一个事件才确认连接成功
+	ctx.Header("Content-Type", eventStream)
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no") // 关闭nginx的缓冲
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case msg, ok := <-sub.Messages:
+			if !ok {
+				return false
+			}
+			if !eventVisible(ctx.Request.Context(), claims, msg) {
+				return true
+			}
+			ctx.Render(-1, sse.Event{Id: msg.Id, Event: msg.Type, Data: string(msg.Data)})
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// 登录用户是否可以接收该消息，区块和商品事件推送给所有登录用户
+func eventVisible(ctx context.Context, claims *auth.Claims, msg *events.Message) bool {
+	if claims.Role == auth.RoleAdmin || claims.Role == lib.RoleRegulator {
+		return true
+	}
+	if msg.Type == events.BlockMessage || msg.Type == eventCommodityCreated {
+		return true
+	}
+
+	var event eventParties
+	if claims.AccountId == "" || json.Unmarshal(msg.Data, &event) != nil {
+		return false
+	}
+	for _, val := range []string{event.AccountId, event.BuyerId, event.SellerId, event.CarrierId} {
+		if val == claims.AccountId {
+			return true
+		}
+	}
+
+	// 温度事件只带有订单ID，从账本查询订单的参与方
+	if event.OrderId != "" && event.BuyerId == "" && event.SellerId == "" && event.CarrierId == "" {
+		order, err := LoadOrder(ctx, event.OrderId)
+		if err != nil || order == nil {
+			return false
+		}
+		return claims.AccountId == order.BuyerId || claims.AccountId == order.SellerId || claims.AccountId == order.CarrierId
+	}
+	return false
+}
@@ -1,11 +1,17 @@
 package controller
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
+	"gdzce.cn/perishable-food/application/auth"
+	bc "gdzce.cn/perishable-food/application/blockchain"
+	"gdzce.cn/perishable-food/application/lib"
+	"gdzce.cn/perishable-food/application/wallet"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,20 +21,96 @@ var (
 		"src/gdzce.cn/perishable-food/application/public/index.html") // 拼接index.html的路径
 )
 
-// 允许跨域
+// gin上下文中保存当前登录用户信息的键
+const (
+	IdentityKey = "identity" // 链码调用身份
+	ClaimsKey   = "claims"   // 令牌中的登录信息
+)
+
+// 后台任务以账户身份上链时获取账户的签名身份，没有钱包时只有本地模拟账本可以按账户生成证书
+// 其他账本没有登记身份时返回错误，不以默认的管理员身份上链
+var AccountIdentity = func(accountId string) (*bc.Identity, error) {
+	if bc.Current.Backend != bc.BackendMock {
+		return nil, fmt.Errorf("account %s has no enrolled identity: %w", accountId, wallet.ErrNotFound)
+	}
+	id := bc.DefaultIdentity()
+	id.AccountId = accountId
+	return id, nil
+}
+
+// 允许跨域访问的来源，逗号分隔，为空时只允许同源访问，*表示允许所有来源
+var AllowedOrigins = splitList(os.Getenv("CORS_ALLOWED_ORIGINS"))
+
+// 跨域，只对允许的来源返回跨域响应头
 func CORS(c *gin.Context) {
-	c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-	c.Writer.Header().Set("Access-Control-Allow-Headers", "*")
-	c.Writer.Header().Set("Access-Control-Allow-Methods", "*")
+	origin := c.GetHeader("Origin")
+	allowed := originAllowed(origin)
+	if allowed {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	}
+	c.Writer.Header().Add("Vary", "Origin")
 
-	if c.Request.Method == "OPTIONS" {
-		c.String(http.StatusOK, "")
+	// 预检请求直接返回
+	if c.Request.Method == "OPTIONS" && origin != "" {
+		if allowed {
+			c.AbortWithStatus(http.StatusNoContent)
+		} else {
+			c.AbortWithStatus(http.StatusForbidden)
+		}
+		return
 	}
 
 	// 调用下个中间件
 	c.Next()
 }
 
+// 解析请求头中的登录令牌，令牌无效时返回401，没有令牌时以未登录状态继续
+func Authenticate(c *gin.Context) {
+	header := c.GetHeader("Authorization")
+	// 浏览器的EventSource不能设置请求头，事件流可以在查询参数access_token中携带令牌
+	if token := c.Query("access_token"); header == "" && token != "" && c.GetHeader("Accept") == eventStream {
+		header = "Bearer " + token
+	}
+	if header == "" {
+		c.Next()
+		return
+	}
+
+	if !strings.HasPrefix(header, "Bearer ") || Tokens == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": auth.ErrInvalidToken.Error()})
+		return
+	}
+	claims, err := Tokens.Parse(strings.TrimPrefix(header, "Bearer "))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 用户以钱包中登记的身份调用链码，没有钱包时（如本地模拟账本）使用默认身份并带上账户，管理员使用默认身份
+	if claims.Role != auth.RoleAdmin {
+		id := bc.DefaultIdentity()
+		if CA != nil {
+			id, err = CA.Identity(claims.User())
+			if err == wallet.ErrNotFound || err == wallet.ErrRevoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		id.AccountId = claims.AccountId
+		c.Set(IdentityKey, id)
+	}
+	c.Set(ClaimsKey, claims)
+
+	// 调用下个中间件
+	c.Next()
+}
+
 // 修复前端内部路由的问题
 func FixVueRouter(c *gin.Context) {
 	// 调用下个中间件
@@ -40,3 +122,60 @@ func FixVueRouter(c *gin.Context) {
 		c.File(IndexHtmlPath)
 	}
 }
+
+// 根据请求头Accept-Language选择语言，取权重最高的受支持语言，没有则使用默认语言
+func language(c *gin.Context) string {
+	lang, weight := lib.DefaultLanguage, 0.0
+	for _, val := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		parts := strings.Split(strings.TrimSpace(val), ";")
+		tag := strings.ToLower(strings.SplitN(parts[0], "-", 2)[0])
+		if _, ok := lib.StatusLabels[tag]; !ok {
+			continue
+		}
+
+		q := 1.0
+		if len(parts) > 1 {
+			if val, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(parts[1]), "q="), 64); err == nil {
+				q = val
+			}
+		}
+		if q > weight {
+			lang, weight = tag, q
+		}
+	}
+	return lang
+}
+
+// 当前请求调用链码的身份，没有登录用户时使用默认身份，只有公开的商品查询可以不登录
+func identity(c *gin.Context) *bc.Identity {
+	if val, ok := c.Get(IdentityKey); ok {
+		if id, ok := val.(*bc.Identity); ok && id != nil {
+			return id
+		}
+	}
+	return bc.DefaultIdentity()
+}
+
+// 来源是否在允许跨域的列表中
+func originAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, val := range AllowedOrigins {
+		if val == "*" || val == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// 拆分逗号分隔的列表，忽略空项
+func splitList(str string) []string {
+	list := make([]string, 0)
+	for _, val := range strings.Split(str, ",") {
+		if val = strings.TrimSpace(val); val != "" {
+			list = append(list, val)
+		}
+	}
+	return list
+}
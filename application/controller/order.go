@@ -2,29 +2,48 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	bc "gdzce.cn/perishable-food/application/blockchain"
+	"gdzce.cn/perishable-food/application/fbeecloud"
 	"gdzce.cn/perishable-food/application/lib"
 	"gdzce.cn/perishable-food/application/repository"
+	"gdzce.cn/perishable-food/application/updater"
 	"github.com/gin-gonic/gin"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
 )
 
 const TemperatureUpdatersInterval = 10 * 1000 // 温度传感器获取间隔 10s
 
-//var TemperatureUpdaters []*FbeeTemperatureUpdater // 用于上传温度
+// 传给链码的时间格式，统一转换为UTC并保留毫秒，链码不受服务器时区影响
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+var Fbee fbeecloud.Gateway // 温度传感器网关
+
+var TemperatureUpdaters *updater.Manager // 用于上传温度
 
 // 订单请求体
 type orderRequest struct {
-	CommodityId string `json:"commodity_id" binding:"required"` // 商品id
-	Id          string `json:"id" binding:"required"`           // 订单id
-	OrderTime   int64  `json:"orderTime" binding:"required"`    // 预计送达时间（时间戳）
-	Status      string `json:"status" binding:"required"`       // 预计送达时间（时间戳）
-	BuyerId     string `json:"buyer" binding:"required"`        // 买家
-	SellerId    string `json:"seller" binding:"required"`       // 卖家
+	CommodityId    string  `json:"commodity_id" binding:"required"`                 // 商品id
+	Id             string  `json:"id" binding:"required"`                           // 订单id
+	OrderTime      int64   `json:"orderTime" binding:"required"`                    // 下单时间（毫秒时间戳）
+	Status         string  `json:"status" binding:"required"`                       // 订单状态
+	BuyerId        string  `json:"buyer" binding:"required"`                        // 买家
+	SellerId       string  `json:"seller" binding:"required"`                       // 卖家
+	DeliverAddress string  `json:"deliverAddress" binding:"required"`               // 配送地址
+	DeliverTime    int64   `json:"deliverTime" binding:"required"`                  // 预计送达时间（毫秒时间戳）
+	Quantity       int64   `json:"quantity" binding:"required,min=1"`               // 数量
+	CarrierId      string  `json:"carrier" binding:"required"`                      // 物流商
+	FreightType    string  `json:"freightType" binding:"required,oneof=rate fixed"` // 物流费计算方式：rate按总额百分比，fixed固定运费
+	Freight        float64 `json:"freight" binding:"min=0"`                         // 物流费百分比或固定运费
 }
 
 // 创建订单
@@ -37,32 +56,42 @@ func CreateOrder(ctx *gin.Context) {
 	}
 
 	// 格式化时间参数，并打印请求参数
-	orderTime := time.Unix(req.OrderTime/1000, 0)
+	orderTime := time.UnixMilli(req.OrderTime)
+	deliverTime := time.UnixMilli(req.DeliverTime)
+	if !deliverTime.After(orderTime) {
+		ctx.String(http.StatusBadRequest, "deliverTime必须晚于orderTime")
+		return
+	}
 	fmt.Println("请求参数：")
 	marshal, err := json.Marshal(req)
 	fmt.Println(string(marshal))
 
 	// 将请求体参数转化为byte数组，发送给区块链，调用链码的createOrder函数
-	resp, err := bc.ChannelExecute("createOrder", [][]byte{
+	resp, err := bc.ChannelExecute(ctx.Request.Context(), identity(ctx), "createOrder", [][]byte{
 		[]byte(req.CommodityId),
 		[]byte(req.Id),
-		[]byte(orderTime.Format("2006-01-02 15:04:05")),
+		[]byte(formatTime(orderTime)),
 		[]byte(req.Status),
 		[]byte(req.BuyerId),
 		[]byte(req.SellerId),
+		[]byte(req.DeliverAddress),
+		[]byte(formatTime(deliverTime)),
+		[]byte(strconv.FormatInt(req.Quantity, 10)),
+		[]byte(req.CarrierId),
+		[]byte(req.FreightType),
+		[]byte(strconv.FormatFloat(req.Freight, 'f', -1, 64)),
 	})
 	if err != nil {
 		ctx.String(http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// 可先忽略，完成基本功能后再完善
-	// 成功返回后，将orderId与txid存到transactionRecords中
-	repository.TransactionRecordList.Push(repository.TransactionRecord{OrderId: req.Id, TxID: resp.TransactionID})
-	err = repository.TransactionRecordList.Save() // 写入文件（数据持久化）
+	// 成功返回后，先将orderId与txid存到transactionRecords中，以便在区块同步前查询
+	// 区块同步时会补全所在区块，并写入缓存文件
+	// 交易已上链，记录失败时等待区块同步补全
+	err = repository.TransactionRecordList.Push(repository.TransactionRecord{OrderId: req.Id, TxID: resp.TransactionID, Event: "OrderCreated"})
 	if err != nil {
-		ctx.String(http.StatusInternalServerError, err.Error())
-		return
+		fmt.Println("记录订单交易失败：", err)
 	}
 
 	// http返回
@@ -71,7 +100,7 @@ func CreateOrder(ctx *gin.Context) {
 
 // 查询订单列表
 func OrderList(ctx *gin.Context) {
-	// 获取请求的请求参数
+	// 获取请求的请求参数，链码按调用者证书确定查询人，只返回其有权查看的订单
 	orderId := ctx.Query("orderId")
 	var args [][]byte
 	if orderId != "" {
@@ -79,7 +108,7 @@ func OrderList(ctx *gin.Context) {
 	}
 
 	// 将请求参数发送给区块链，调用链码的queryOrderList
-	resp, err := bc.ChannelQuery("queryOrderList", args)
+	resp, err := bc.ChannelQuery(ctx.Request.Context(), identity(ctx), "queryOrderList", args)
 	if err != nil {
 		ctx.String(http.StatusInternalServerError, err.Error())
 		return
@@ -88,30 +117,39 @@ func OrderList(ctx *gin.Context) {
 	var Orders []lib.Order
 	_ = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &Orders)
 
-	// 可先忽略，完成基本功能后再完善
-	// 循环查询该订单号（orderId）对应的txid
+	// 循环查询该订单号（orderId）对应的所有txid，第一笔为创建订单的交易
 	for index, order := range Orders {
-		_, tr := repository.TransactionRecordList.FindOneByOrderId(order.Id)
-		fmt.Printf("result: %+v\n", tr)
-		Orders[index].TransactionId = tr.TxID
+		trList, err := repository.TransactionRecordList.FindByOrderId(order.Id)
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		Orders[index].Transactions = []*lib.Transaction{}
+		for _, tr := range trList {
+			Orders[index].Transactions = append(Orders[index].Transactions, &lib.Transaction{TxId: tr.TxID, Event: tr.Event, Block: tr.Block})
+		}
+		if len(Orders[index].Transactions) > 0 {
+			Orders[index].TransactionId = Orders[index].Transactions[0].TxId
+		}
+	}
+
+	// 账本中存储的是状态码，按请求的语言转换为显示名称（前端按显示名称判断状态）
+	lang := language(ctx)
+	for index, order := range Orders {
+		Orders[index].StatusCode = order.Status
+		Orders[index].Status = lib.StatusLabel(order.Status, lang)
 	}
 
 	// 将结果返回
 	ctx.JSON(http.StatusOK, Orders)
 }
 
-// 订单状态枚举键值对
-var statusMap = map[string]string{
-	"New":        "新建",
-	"Processing": "运送中",
-	"Done":       "完成",
-	"Canceled":   "取消",
-}
-
 // 更新订单状态请求体
 type updateOrderStatusRequest struct {
-	OrderId string `form:"order_id" json:"order_id" binding:"required"`
-	Status  string `form:"status" json:"status" binding:"required"`
+	OrderId  string `form:"order_id" json:"order_id" binding:"required"`
+	Status   string `form:"status" json:"status" binding:"required"`
+	Operator string `form:"operator" json:"operator" binding:"required"` // 操作人账户ID，运送中时必须为订单的物流商
+	SensorId string `form:"sensor_id" json:"sensor_id"`                  // 运送中时绑定的温度传感器，为空时使用网关默认传感器
 }
 
 // 更新订单状态
@@ -124,7 +162,7 @@ func UpdateOrderStatus(ctx *gin.Context) {
 	}
 
 	// 检查Status字段是否有错
-	if _, ok := statusMap[req.Status]; !ok {
+	if !lib.IsValidStatus(req.Status) {
 		ctx.String(http.StatusBadRequest, "status字段错误")
 		return
 	}
@@ -134,41 +172,167 @@ func UpdateOrderStatus(ctx *gin.Context) {
 	marshal, err := json.Marshal(req) // 转换成json
 	fmt.Println(string(marshal))
 
-	//switch req.Status {
-	//// 运送中时
-	//case "Processing":
-	//	// 构建温度更新对象
-	//	updater := &FbeeTemperatureUpdater{
-	//		OrderId:  req.OrderId,
-	//		Interval: TemperatureUpdatersInterval,
-	//	}
-	//	// 记录当前订单的温度获取线程
-	//	TemperatureUpdaters = append(TemperatureUpdaters, updater)
-	//	// 温度传感器定时获取启动
-	//	updater.Start()
-	//
-	//// 订单完成时
-	//case "Done":
-	//	for i, updater := range TemperatureUpdaters {
-	//		if updater.OrderId == req.OrderId {
-	//			// 停止定时更新器
-	//			updater.Stop()
-	//			// 从全局更新器数组中删除该更新器
-	//			TemperatureUpdaters = append(TemperatureUpdaters[:i], TemperatureUpdaters[i+1:]...)
-	//		}
-	//	}
-	//}
-
 	// 调用链码
-	resp, err := bc.ChannelExecute("updateOrderStatus", [][]byte{
+	args := [][]byte{
 		[]byte(req.OrderId),
 		[]byte(req.Status),
-	})
+		[]byte(req.Operator),
+	}
+	if req.SensorId != "" {
+		args = append(args, []byte(req.SensorId))
+	}
+	resp, err := bc.ChannelExecute(ctx.Request.Context(), identity(ctx), "updateOrderStatus", args)
 	if err != nil {
 		ctx.String(http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// 订单状态更新成功后，启动或停止该订单的温度更新器
+	if TemperatureUpdaters != nil {
+		switch req.Status {
+		// 运送中时，温度传感器定时获取启动
+		case lib.StatusProcessing:
+			if err := startTemperatureUpdater(req.OrderId, req.SensorId, req.Operator); err != nil {
+				fmt.Printf("订单%s温度更新器启动失败：%s\n", req.OrderId, err)
+			}
+		// 订单完成或取消时，停止定时更新器
+		case lib.StatusDone, lib.StatusCanceled:
+			TemperatureUpdaters.Stop(req.OrderId)
+		}
+	}
+
 	// 将结果返回
 	ctx.JSON(http.StatusOK, resp)
 }
+
+// 温度读数
+type temperatureReading struct {
+	Value      *float64 `json:"value" binding:"required"`             // 温度值
+	Unit       string   `json:"unit" binding:"omitempty,oneof=C F"`   // 温度单位，C为摄氏度（默认），F为华氏度
+	RecordTime int64    `json:"record_time" binding:"required,min=1"` // 记录时间（毫秒时间戳）
+	SensorId   string   `json:"sensor_id"`                            // 传感器ID
+}
+
+// 更新订单温度请求体，可以一次上传一条或多条读数
+type updateOrderTemperatureRequest struct {
+	OrderId  string               `json:"order_id" binding:"required"`
+	Operator string               `json:"operator" binding:"required"` // 上传温度的物流商账户ID
+	Readings []temperatureReading `json:"readings" binding:"required,min=1,dive"`
+}
+
+// 单条读数的上链结果
+type temperatureResult struct {
+	Index    int               `json:"index"`    // 读数在请求中的下标
+	Accepted bool              `json:"accepted"` // 是否上链成功
+	TxID     fab.TransactionID `json:"tx_id,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// 更新订单温度，供手动录入和物联网网关上传温度
+func UpdateOrderTemperature(ctx *gin.Context) {
+	// 解析请求体
+	req := new(updateOrderTemperatureRequest)
+	if err := ctx.ShouldBind(req); err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	// 逐条调用链码，单条失败不影响其他读数
+	results := make([]temperatureResult, 0, len(req.Readings))
+	accepted := 0
+	for i, val := range req.Readings {
+		temperature := *val.Value
+		if val.Unit == "F" {
+			temperature = (temperature - 32) * 5 / 9
+		}
+
+		resp, err := executeUpdateOrderTemperature(ctx.Request.Context(), identity(ctx), req.OrderId, req.Operator, &fbeecloud.Reading{
+			SensorId:    val.SensorId,
+			Temperature: temperature,
+			RecordTime:  time.UnixMilli(val.RecordTime),
+		})
+
+		result := temperatureResult{Index: i}
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Accepted = true
+			result.TxID = resp.TransactionID
+			accepted++
+		}
+		results = append(results, result)
+	}
+
+	// 将结果返回
+	ctx.JSON(http.StatusOK, gin.H{
+		"order_id": req.OrderId,
+		"accepted": accepted,
+		"results":  results,
+	})
+}
+
+// 以id的身份调用链码的updateOrderTemperature记录一条温度
+func executeUpdateOrderTemperature(ctx context.Context, id *bc.Identity, orderId, operatorId string, reading *fbeecloud.Reading) (channel.Response, error) {
+	args := [][]byte{
+		[]byte(orderId),
+		[]byte(strconv.FormatFloat(reading.Temperature, 'f', -1, 64)),
+		[]byte(formatTime(reading.RecordTime)),
+		[]byte(operatorId),
+	}
+	if reading.SensorId != "" {
+		args = append(args, []byte(reading.SensorId))
+	}
+	return bc.ChannelExecute(ctx, id, "updateOrderTemperature", args)
+}
+
+// 以物流商的身份将传感器读数上链，供温度更新器使用
+func SubmitTemperature(orderId, operatorId string, reading *fbeecloud.Reading) error {
+	id, err := AccountIdentity(operatorId)
+	if err != nil {
+		return err
+	}
+	_, err = executeUpdateOrderTemperature(context.Background(), id, orderId, operatorId, reading)
+	// 链码以StatusOrderClosed拒绝非运送中订单的温度，此时停止该订单的更新器
+	if code, ok := bc.ChaincodeStatus(err); ok && code == bc.StatusOrderClosed {
+		return fmt.Errorf("%w: %s", updater.ErrOrderClosed, err)
+	}
+	return err
+}
+
+// 以物流商的身份启动订单的温度更新器，物流商没有登记身份时不启动
+func startTemperatureUpdater(orderId, sensorId, carrierId string) error {
+	if _, err := AccountIdentity(carrierId); err != nil {
+		return err
+	}
+	return TemperatureUpdaters.Start(orderId, sensorId, carrierId)
+}
+
+// 应用重启后，根据账本中运送中的订单重建温度更新器
+func RestoreTemperatureUpdaters() error {
+	resp, err := bc.ChannelQuery(context.Background(), bc.DefaultIdentity(), "queryOrderList", [][]byte{})
+	if err != nil {
+		return err
+	}
+
+	var orders []lib.Order
+	if len(resp.Payload) != 0 {
+		if err := json.Unmarshal(resp.Payload, &orders); err != nil {
+			return err
+		}
+	}
+
+	for _, order := range orders {
+		if order.Status != lib.StatusProcessing {
+			continue
+		}
+		// 旧版本的订单没有物流商，无法确定上传温度的账户
+		if order.CarrierId == "" {
+			fmt.Printf("订单%s没有物流商，不恢复温度更新器\n", order.Id)
+			continue
+		}
+		if err := startTemperatureUpdater(order.Id, order.SensorId, order.CarrierId); err != nil {
+			fmt.Printf("订单%s温度更新器恢复失败：%s\n", order.Id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"gdzce.cn/perishable-food/application/auth"
+	bc "gdzce.cn/perishable-food/application/blockchain"
+	"gdzce.cn/perishable-food/application/lib"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const (
+	bodyKey       = "policyBody" // gin上下文中缓存已解析的json请求体的键
+	defaultMemory = 32 << 20     // 解析multipart表单时的内存上限，与gin绑定时一致
+)
+
+// 路由的访问策略
+type Policy struct {
+	Public bool     // 不需要登录
+	Roles  []string // 允许的角色，为空时允许所有登录用户
+	Checks []Check  // 归属检查，管理员不做检查
+}
+
+// 归属检查，不通过时返回拒绝的原因，请求中缺少字段时由处理函数校验
+type Check func(c *gin.Context, claims *auth.Claims) error
+
+// 从账本读取订单、商品和账户，用于归属检查和本地模拟账本的登录，测试时可替换
+var (
+	LoadOrder = func(ctx context.Context, orderId string) (*lib.Order, error) {
+		resp, err := bc.ChannelQuery(ctx, bc.DefaultIdentity(), "queryOrderList", [][]byte{[]byte(orderId)})
+		if err != nil {
+			return nil, err
+		}
+		var orders []*lib.Order
+		if len(resp.Payload) != 0 {
+			if err := json.Unmarshal(resp.Payload, &orders); err != nil {
+				return nil, err
+			}
+		}
+		for _, order := range orders {
+			if order.Id == orderId {
+				return order, nil
+			}
+		}
+		return nil, nil
+	}
+	LoadCommodity = func(ctx context.Context, commodityId string) (*lib.Commodity, error) {
+		resp, err := bc.ChannelQuery(ctx, bc.DefaultIdentity(), "queryCommodityList", [][]byte{[]byte(commodityId)})
+		if err != nil {
+			return nil, err
+		}
+		var commodities []*lib.Commodity
+		if len(resp.Payload) != 0 {
+			if err := json.Unmarshal(resp.Payload, &commodities); err != nil {
+				return nil, err
+			}
+		}
+		for _, commodity := range commodities {
+			if commodity.Id == commodityId {
+				return commodity, nil
+			}
+		}
+		return nil, nil
+	}
+	LoadAccount = func(ctx context.Context, accountId string) (*lib.Account, error) {
+		resp, err := bc.ChannelQuery(ctx, bc.DefaultIdentity(), "queryAccount", [][]byte{[]byte(accountId)})
+		if err != nil {
+			return nil, err
+		}
+		var accounts []*lib.Account
+		if len(resp.Payload) != 0 {
+			if err := json.Unmarshal(resp.Payload, &accounts); err != nil {
+				return nil, err
+			}
+		}
+		for _, account := range accounts {
+			if account.Id == accountId {
+				return account, nil
+			}
+		}
+		return nil, nil
+	}
+)
+
+// 按策略校验当前请求，未登录返回401，无权访问返回403和原因
+func Authorize(policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy.Public {
+			c.Next()
+			return
+		}
+
+		claims, ok := c.Get(ClaimsKey)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "login required"})
+			return
+		}
+		user := claims.(*auth.Claims)
+
+		if len(policy.Roles) != 0 && !containsRole(policy.Roles, user.Role) {
+			forbid(c, fmt.Errorf("role %s is not allowed, requires one of %s", user.Role, strings.Join(policy.Roles, ", ")))
+			return
+		}
+		if user.Role != auth.RoleAdmin {
+			for _, check := range policy.Checks {
+				if err := check(c, user); err != nil {
+					forbid(c, err)
+					return
+				}
+			}
+		}
+
+		// 调用下个中间件
+		c.Next()
+	}
+}
+
+// 请求中的账户字段必须是登录账户
+func Self(field string) Check {
+	return func(c *gin.Context, claims *auth.Claims) error {
+		val, err := requestField(c, field)
+		if err != nil {
+			return err
+		}
+		if val != "" && val != claims.AccountId {
+			return fmt.Errorf("%s %s is not the logged-in account %s", field, val, claims.AccountId)
+		}
+		return nil
+	}
+}
+
+// 登录账户必须是订单中与其角色对应的一方，即供货商是卖家、物流商是承运方、买家是买家
+func OrderParty(field string) Check {
+	return func(c *gin.Context, claims *auth.Claims) error {
+		orderId, err := requestField(c, field)
+		if err != nil || orderId == "" {
+			return err
+		}
+		order, err := LoadOrder(c.Request.Context(), orderId)
+		if err != nil {
+			return fmt.Errorf("query order %s error: %s", orderId, err)
+		}
+		if order == nil {
+			return fmt.Errorf("order %s not exists", orderId)
+		}
+
+		party := map[string]string{
+			lib.RoleSupplier: order.SellerId,
+			lib.RoleCarrier:  order.CarrierId,
+			lib.RoleBuyer:    order.BuyerId,
+		}[claims.Role]
+		if party == "" || party != claims.AccountId {
+			return fmt.Errorf("account %s is not the %s of order %s", claims.AccountId, claims.Role, orderId)
+		}
+		return nil
+	}
+}
+
+// 请求中的商品必须属于请求中ownerField指定的账户
+func CommodityOwner(field, ownerField string) Check {
+	return func(c *gin.Context, claims *auth.Claims) error {
+		commodityId, err := requestField(c, field)
+		if err != nil || commodityId == "" {
+			return err
+		}
+		owner, err := requestField(c, ownerField)
+		if err != nil {
+			return err
+		}
+		commodity, err := LoadCommodity(c.Request.Context(), commodityId)
+		if err != nil {
+			return fmt.Errorf("query commodity %s error: %s", commodityId, err)
+		}
+		if commodity == nil {
+			return fmt.Errorf("commodity %s not exists", commodityId)
+		}
+		if commodity.OwnerId != owner {
+			return fmt.Errorf("commodity %s does not belong to %s %s", commodityId, ownerField, owner)
+		}
+		return nil
+	}
+}
+
+func forbid(c *gin.Context, reason error) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": reason.Error()})
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, val := range roles {
+		if val == role {
+			return true
+		}
+	}
+	return false
+}
+
+// 读取请求参数，与处理函数的ShouldBind使用相同的来源，读取后恢复请求体供处理函数绑定
+// json请求只读请求体，multipart表单只读表单，其他表单读表单和查询参数，表单优先
+// 无法读取的请求格式返回错误，避免处理函数绑定的值未经检查
+func requestField(c *gin.Context, field string) (string, error) {
+	switch b := binding.Default(c.Request.Method, c.ContentType()); b {
+	case binding.JSON:
+		return jsonField(c, field), nil
+	case binding.FormMultipart:
+		if err := c.Request.ParseMultipartForm(defaultMemory); err != nil {
+			return "", err
+		}
+		if vals := c.Request.MultipartForm.Value[field]; len(vals) != 0 {
+			return vals[0], nil
+		}
+		return "", nil
+	case binding.Form:
+		if err := c.Request.ParseForm(); err != nil {
+			return "", err
+		}
+		return c.Request.Form.Get(field), nil
+	default:
+		return "", fmt.Errorf("content type %s is not supported", c.ContentType())
+	}
+}
+
+// 读取json请求体中的字段，请求体只解析一次
+func jsonField(c *gin.Context, field string) string {
+	body, ok := c.Get(bodyKey)
+	if !ok {
+		data, _ := ioutil.ReadAll(c.Request.Body)
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+		fields := make(map[string]interface{})
+		_ = json.Unmarshal(data, &fields)
+		c.Set(bodyKey, fields)
+		body = fields
+	}
+	switch val := body.(map[string]interface{})[field].(type) {
+	case string:
+		return val
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"net/http"
+
+	"gdzce.cn/perishable-food/application/wallet"
+	"github.com/gin-gonic/gin"
+)
+
+var CA *wallet.CA // 组织的Fabric CA，未配置钱包时为空
+
+// 注册用户请求体
+type registerUserRequest struct {
+	User      string `json:"user" form:"user" binding:"required"`                                        // 用户名
+	Secret    string `json:"secret" form:"secret"`                                                       // 登记口令，为空时由CA生成
+	AccountId string `json:"account_id" form:"account_id" binding:"required"`                            // 绑定的链上账户ID
+	Role      string `json:"role" form:"role" binding:"required,oneof=supplier carrier buyer regulator"` // 账户角色
+}
+
+// 在CA中注册用户
+func RegisterUser(ctx *gin.Context) {
+	if !caReady(ctx) {
+		return
+	}
+
+	// 解析请求体
+	req := new(registerUserRequest)
+	if err := ctx.ShouldBind(req); err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	// 管理员只存在于应用程序中，不能在CA中注册同名用户
+	if req.User == AdminUser {
+		ctx.String(http.StatusBadRequest, "user %s is reserved", req.User)
+		return
+	}
+
+	secret, err := CA.Register(req.User, req.Secret, req.AccountId, req.Role)
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// http返回
+	ctx.JSON(http.StatusOK, gin.H{"user": req.User, "secret": secret})
+}
+
+// 登记用户请求体
+type enrollUserRequest struct {
+	User     string `json:"user" form:"user" binding:"required"`               // 用户名
+	Secret   string `json:"secret" form:"secret" binding:"required"`           // 登记口令
+	Password string `json:"password" form:"password" binding:"required,min=8"` // 登录口令
+}
+
+// 登记用户，证书、私钥和登录口令的哈希保存到钱包
+func EnrollUser(ctx *gin.Context) {
+	if !caReady(ctx) {
+		return
+	}
+
+	// 解析请求体
+	req := new(enrollUserRequest)
+	if err := ctx.ShouldBind(req); err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := CA.Enroll(req.User, req.Secret); err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	entry, err := setPassword(req.User, req.Password)
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// http返回
+	ctx.JSON(http.StatusOK, userInfo(entry))
+}
+
+// 重新登记用户请求体
+type reenrollUserRequest struct {
+	User string `json:"user" form:"user" binding:"required"` // 用户名
+}
+
+// 重新登记用户，换发证书
+func ReenrollUser(ctx *gin.Context) {
+	if !caReady(ctx) {
+		return
+	}
+
+	// 解析请求体
+	req := new(reenrollUserRequest)
+	if err := ctx.ShouldBind(req); err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	entry, err := CA.Reenroll(req.User)
+	if err == wallet.ErrNotFound {
+		ctx.String(http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// http返回
+	ctx.JSON(http.StatusOK, userInfo(entry))
+}
+
+// 吊销用户请求体
+type revokeUserRequest struct {
+	User   string `json:"user" form:"user" binding:"required"` // 用户名
+	Reason string `json:"reason" form:"reason"`                // 吊销原因
+}
+
+// 吊销用户的证书
+func RevokeUser(ctx *gin.Context) {
+	if !caReady(ctx) {
+		return
+	}
+
+	// 解析请求体
+	req := new(revokeUserRequest)
+	if err := ctx.ShouldBind(req); err != nil {
+		_ = ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := CA.Revoke(req.User, req.Reason); err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// http返回
+	ctx.JSON(http.StatusOK, gin.H{"user": req.User, "revoked": true})
+}
+
+// 未配置钱包时返回503
+func caReady(ctx *gin.Context) bool {
+	if CA == nil {
+		ctx.String(http.StatusServiceUnavailable, "wallet is not configured")
+		return false
+	}
+	return true
+}
+
+// 返回给前端的身份信息，不包含私钥
+func userInfo(entry *wallet.Entry) gin.H {
+	return gin.H{
+		"user":      entry.User,
+		"org":       entry.Org,
+		"mspId":     entry.MspId,
+		"accountId": entry.AccountId,
+		"role":      entry.Role,
+		"revoked":   entry.Revoked,
+	}
+}
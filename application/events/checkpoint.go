@@ -0,0 +1,70 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 检查点文件所在目录，每个合约一个文件
+var Dir = "./data/events"
+
+// 区块检查点，记录最后处理完成的区块，重启后从下一个区块继续
+type Checkpoint struct {
+	Path string
+
+	mutex sync.Mutex
+}
+
+// 检查点文件内容
+type checkpointFile struct {
+	Block uint64 `json:"block"` // 最后处理完成的区块
+}
+
+// 合约的检查点，保存在Dir目录下
+func NewCheckpoint(contract string) *Checkpoint {
+	return &Checkpoint{Path: filepath.Join(Dir, contract+".checkpoint")}
+}
+
+// 读取检查点，文件不存在时ok为false
+func (c *Checkpoint) Load() (block uint64, ok bool, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	data, err := ioutil.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	var file checkpointFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, false, fmt.Errorf("parse checkpoint %s error: %s", c.Path, err)
+	}
+	return file.Block, true, nil
+}
+
+// 记录处理完成的区块
+func (c *Checkpoint) Save(block uint64) error {
+	data, err := json.Marshal(checkpointFile{Block: block})
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0700); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免写入中断时损坏检查点
+	tmp := c.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.Path)
+}
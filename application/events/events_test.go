@@ -0,0 +1,212 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	bc "gdzce.cn/perishable-food/application/blockchain"
+	"gdzce.cn/perishable-food/application/repository"
+)
+
+// 假事件源，每次订阅从from开始推送已有区块，记录订阅的起始区块
+type fakeSource struct {
+	blocks []*bc.Block
+
+	mutex sync.Mutex
+	froms []uint64
+}
+
+func (s *fakeSource) Blocks(ctx context.Context, from uint64) (<-chan *bc.Block, error) {
+	s.mutex.Lock()
+	s.froms = append(s.froms, from)
+	s.mutex.Unlock()
+	blocks := make(chan *bc.Block)
+	go func() {
+		defer close(blocks)
+		for _, block := range s.blocks {
+			if block.Number < from {
+				continue
+			}
+			select {
+			case blocks <- block:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return blocks, nil
+}
+
+func newFakeSource(n int) *fakeSource {
+	source := new(fakeSource)
+	for i := 0; i < n; i++ {
+		block := &bc.Block{Number: uint64(i)}
+		if i%2 == 1 {
+			block.Events = []*bc.Event{{Block: block.Number, TxId: "tx", Name: "OrderCreated", Payload: []byte(`{"orderId":"1"}`)}}
+		}
+		source.blocks = append(source.blocks, block)
+	}
+	return source
+}
+
+// 检查点不存在时ok为false，保存后能读出
+func TestCheckpoint(t *testing.T) {
+	checkpoint := &Checkpoint{Path: filepath.Join(t.TempDir(), "events", "test.checkpoint")}
+	if _, ok, err := checkpoint.Load(); ok || err != nil {
+		t.Fatalf("expected no checkpoint, got %v, %v", ok, err)
+	}
+	if err := checkpoint.Save(12); err != nil {
+		t.Fatal(err)
+	}
+	if block, ok, err := checkpoint.Load(); !ok || err != nil || block != 12 {
+		t.Fatalf("expected block 12, got %d, %v, %v", block, ok, err)
+	}
+}
+
+// 按类型订阅，取消订阅后不再接收
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	all := hub.Subscribe()
+	orders := hub.Subscribe("OrderCreated")
+	hub.Publish(&Message{Id: "1", Type: BlockMessage})
+	hub.Publish(&Message{Id: "1-0", Type: "OrderCreated"})
+
+	if len(all.Messages) != 2 || len(orders.Messages) != 1 {
+		t.Fatalf("expected 2 and 1 messages, got %d and %d", len(all.Messages), len(orders.Messages))
+	}
+	if msg := <-orders.Messages; msg.Id != "1-0" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	orders.Close()
+	orders.Close()
+	hub.Publish(&Message{Id: "2-0", Type: "OrderCreated"})
+	if _, ok := <-orders.Messages; ok || hub.Len() != 1 {
+		t.Fatal("closed subscription should not receive messages")
+	}
+
+	// 处理慢的订阅者丢弃新消息，不阻塞推送
+	for i := 0; i < SubscriberBuffer*2; i++ {
+		hub.Publish(&Message{Type: BlockMessage})
+	}
+	if len(all.Messages) != SubscriberBuffer {
+		t.Fatalf("expected %d buffered messages, got %d", SubscriberBuffer, len(all.Messages))
+	}
+}
+
+// 推送区块和事件并记录检查点，重启后从检查点的下一个区块继续
+func TestListener1(t *testing.T) {
+	checkpoint := &Checkpoint{Path: filepath.Join(t.TempDir(), "test.checkpoint")}
+	hub := NewHub()
+	sub := hub.Subscribe()
+	listener := &Listener{Source: newFakeSource(4), Checkpoint: checkpoint, Hub: hub}
+	if err := listener.listen(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 4个区块消息和2个事件消息
+	if len(sub.Messages) != 6 {
+		t.Fatalf("expected 6 messages, got %d", len(sub.Messages))
+	}
+	<-sub.Messages
+	if msg := <-sub.Messages; msg.Id != "1" || msg.Type != BlockMessage || string(msg.Data) != `{"number":1,"events":1}` {
+		t.Fatalf("unexpected block message %+v", msg)
+	}
+	if msg := <-sub.Messages; msg.Id != "1-0" || msg.Type != "OrderCreated" || string(msg.Data) != `{"orderId":"1"}` {
+		t.Fatalf("unexpected event message %+v", msg)
+	}
+	if block, _, _ := checkpoint.Load(); block != 3 {
+		t.Fatalf("expected checkpoint 3, got %d", block)
+	}
+
+	source := newFakeSource(6)
+	restarted := &Listener{Source: source, Checkpoint: checkpoint}
+	if err := restarted.listen(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if source.froms[0] != 4 {
+		t.Fatalf("expected to resume from block 4, got %d", source.froms[0])
+	}
+	if block, _, _ := checkpoint.Load(); block != 5 {
+		t.Fatalf("expected checkpoint 5, got %d", block)
+	}
+}
+
+// 处理失败时不记录检查点，重新订阅时从失败的区块开始
+func TestListener2(t *testing.T) {
+	checkpoint := &Checkpoint{Path: filepath.Join(t.TempDir(), "test.checkpoint")}
+	failed := false
+	source := newFakeSource(4)
+	listener := &Listener{Source: source, Checkpoint: checkpoint, Handlers: []Handler{func(block *bc.Block) error {
+		if block.Number == 2 && !failed {
+			failed = true
+			return errors.New("handler error")
+		}
+		return nil
+	}}, Retry: 10 * time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go listener.Run(ctx)
+	for {
+		if block, _, _ := checkpoint.Load(); block == 3 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("listener did not recover")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	if len(source.froms) < 2 || source.froms[0] != 0 || source.froms[1] != 2 {
+		t.Fatalf("expected to resubscribe from block 2, got %v", source.froms)
+	}
+}
+
+// 从链码事件同步每个订单的所有交易，重新加载缓存后从下一个区块继续
+func TestRecordTransactions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactionRecord.json")
+	if err := ioutil.WriteFile(path, []byte(`[{"order_id":"1","tx_id":"tx1"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	records := new(repository.TransactionRecords)
+	if err := records.Open(path); err != nil || records.NextBlock() != 0 {
+		t.Fatalf("expected legacy records synced from block 0, got %d, %v", records.NextBlock(), err)
+	}
+
+	source := &fakeSource{blocks: []*bc.Block{
+		{Number: 0},
+		{Number: 1, Events: []*bc.Event{
+			{TxId: "tx1", Name: "OrderCreated", Payload: []byte(`{"orderId":"1"}`)},
+			{TxId: "tx2", Name: "AccountCreated", Payload: []byte(`{"accountId":"2"}`)},
+		}},
+		{Number: 2, Events: []*bc.Event{{TxId: "tx3", Name: "OrderStatusChanged", Payload: []byte(`{"orderId":"1"}`)}}},
+	}}
+	listener := &Listener{Source: source, From: records.NextBlock(), Handlers: []Handler{RecordTransactions(records)}}
+	if err := listener.listen(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := records.FindByOrderId("1")
+	if len(list) != 2 || list[0].TxID != "tx1" || list[0].Block != 1 || list[1].Event != "OrderStatusChanged" {
+		t.Fatalf("unexpected records %+v", list)
+	}
+
+	reloaded := new(repository.TransactionRecords)
+	if err := reloaded.Open(path); err != nil || reloaded.NextBlock() != 3 {
+		t.Fatalf("expected records synced to block 3, got %s, %v", reloaded.String(), err)
+	}
+	// 重复处理已同步的区块不产生重复记录
+	err := RecordTransactions(reloaded)(source.blocks[2])
+	if list, _ := reloaded.FindByOrderId("1"); err != nil || len(list) != 2 {
+		t.Fatalf("duplicated records %s, %v", reloaded.String(), err)
+	}
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// 每个订阅者缓存的消息数，客户端处理不过来时丢弃新消息
+var SubscriberBuffer = 64
+
+// 推送给客户端的消息
+type Message struct {
+	Id   string          // 消息ID，区块号-序号
+	Type string          // 消息类型：block或链码事件名
+	Data json.RawMessage // JSON内容
+}
+
+// 消息分发，把监听到的事件推送给所有订阅者，并发安全
+type Hub struct {
+	mutex       sync.Mutex
+	subscribers map[*Subscription]struct{}
+}
+
+// 订阅，Messages在取消订阅后关闭
+type Subscription struct {
+	Messages <-chan *Message
+
+	hub      *Hub
+	messages chan *Message
+	types    map[string]bool // 只接收的消息类型，为空时接收所有消息
+	closed   bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscription]struct{})}
+}
+
+// 订阅指定类型的消息，不指定时订阅所有消息
+func (h *Hub) Subscribe(types ...string) *Subscription {
+	messages := make(chan *Message, SubscriberBuffer)
+	sub := &Subscription{Messages: messages, hub: h, messages: messages}
+	if len(types) != 0 {
+		sub.types = make(map[string]bool, len(types))
+		for _, val := range types {
+			sub.types[val] = true
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// 取消订阅
+func (s *Subscription) Close() {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.hub.subscribers, s)
+	close(s.messages)
+}
+
+// 推送消息，不等待处理慢的订阅者
+func (h *Hub) Publish(msg *Message) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for sub := range h.subscribers {
+		if sub.types != nil && !sub.types[msg.Type] {
+			continue
+		}
+		select {
+		case sub.messages <- msg:
+		default:
+		}
+	}
+}
+
+// 订阅者数量
+func (h *Hub) Len() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.subscribers)
+}
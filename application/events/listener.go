@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bc "gdzce.cn/perishable-food/application/blockchain"
+)
+
+const BlockMessage = "block" // 区块消息的类型
+
+// 连接断开或处理失败后重新订阅的间隔
+var RetryInterval = 5 * time.Second
+
+// 区块消息内容
+type blockMessage struct {
+	Number uint64 `json:"number"` // 区块号
+	Events int    `json:"events"` // 区块中本合约的事件数
+}
+
+// 区块处理函数，返回错误时稍后重新处理该区块
+type Handler func(block *bc.Block) error
+
+// 事件监听，按顺序处理区块，推送给订阅者后记录检查点
+type Listener struct {
+	Source     bc.EventSource
+	Checkpoint *Checkpoint   // 为空时不记录，每次启动从From开始
+	From       uint64        // 没有检查点时的起始区块
+	Hub        *Hub          // 为空时不推送
+	Handlers   []Handler     // 在推送和记录检查点之前调用
+	Retry      time.Duration // 重新订阅的间隔，为0时使用RetryInterval
+
+	next    uint64 // 下一个要处理的区块
+	started bool
+}
+
+// 持续监听直到ctx取消，连接断开时从下一个未处理的区块重新订阅
+func (l *Listener) Run(ctx context.Context) {
+	for {
+		err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("event source closed")
+		}
+		retry := l.Retry
+		if retry == 0 {
+			retry = RetryInterval
+		}
+		fmt.Printf("事件监听中断，%s后从区块%d重新订阅：%s\n", retry, l.next, err)
+
+		select {
+		case <-time.After(retry):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 订阅一次，处理到通道关闭或出错为止
+func (l *Listener) listen(ctx context.Context) error {
+	if err := l.start(); err != nil {
+		return err
+	}
+
+	// 处理失败时取消本次订阅
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocks, err := l.Source.Blocks(ctx, l.next)
+	if err != nil {
+		return err
+	}
+	for block := range blocks {
+		if err := l.handle(block); err != nil {
+			return fmt.Errorf("block %d: %s", block.Number, err)
+		}
+		l.next = block.Number + 1
+	}
+	return nil
+}
+
+// 首次订阅时从检查点的下一个区块开始
+func (l *Listener) start() error {
+	if l.started {
+		return nil
+	}
+	l.next = l.From
+	if l.Checkpoint == nil {
+		l.started = true
+		return nil
+	}
+	block, ok, err := l.Checkpoint.Load()
+	if err != nil {
+		return err
+	}
+	if ok {
+		l.next = block + 1
+	}
+	l.started = true
+	return nil
+}
+
+// 处理一个区块：调用处理函数，推送区块和事件，记录检查点
+func (l *Listener) handle(block *bc.Block) error {
+	for _, handler := range l.Handlers {
+		if err := handler(block); err != nil {
+			return err
+		}
+	}
+
+	if l.Hub != nil {
+		data, _ := json.Marshal(blockMessage{Number: block.Number, Events: len(block.Events)})
+		l.Hub.Publish(&Message{Id: fmt.Sprintf("%d", block.Number), Type: BlockMessage, Data: data})
+		for i, event := range block.Events {
+			// 链码写入的事件内容为JSON，原样推送
+			data := json.RawMessage(event.Payload)
+			if !json.Valid(data) {
+				data, _ = json.Marshal(string(event.Payload))
+			}
+			l.Hub.Publish(&Message{Id: fmt.Sprintf("%d-%d", block.Number, i), Type: event.Name, Data: data})
+		}
+	}
+
+	if l.Checkpoint != nil {
+		return l.Checkpoint.Save(block.Number)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package events
+
+import (
+	"encoding/json"
+
+	bc "gdzce.cn/perishable-food/application/blockchain"
+	"gdzce.cn/perishable-food/application/repository"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// 链码事件中的订单id
+type orderEvent struct {
+	OrderId string `json:"orderId"`
+}
+
+// 从区块的链码事件同步订单id与交易id的对应关系，每笔涉及订单的交易一条记录
+// 监听器应从records.NextBlock()开始订阅，records同时充当检查点
+func RecordTransactions(records repository.Store) Handler {
+	return func(block *bc.Block) error {
+		var list []repository.TransactionRecord
+		for _, event := range block.Events {
+			var payload orderEvent
+			if err := json.Unmarshal(event.Payload, &payload); err != nil || payload.OrderId == "" {
+				continue
+			}
+			list = append(list, repository.TransactionRecord{
+				OrderId: payload.OrderId,
+				TxID:    fab.TransactionID(event.TxId),
+				Event:   event.Name,
+			})
+		}
+		return records.Sync(block.Number, list)
+	}
+}
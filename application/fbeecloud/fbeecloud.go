@@ -0,0 +1,243 @@
+package fbeecloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ConfigPath     = "fbeecloud.json" // 网关配置文件路径
+	RequestTimeout = 10 * time.Second // 请求网关的超时时间
+)
+
+var ErrUnauthorized = errors.New("fbeecloud: unauthorized") // token无效或已过期
+
+// 网关配置，对应fbeecloud.json
+type Config struct {
+	Url    string // 网关地址
+	Port   string // 网关端口
+	DevKey string // 设备密钥
+}
+
+// 传感器
+type Sensor struct {
+	Id     string `json:"id"`     // 传感器ID
+	Name   string `json:"name"`   // 传感器名称
+	Online bool   `json:"online"` // 是否在线
+}
+
+// 温度读数
+type Reading struct {
+	SensorId    string    `json:"sensor_id"`   // 传感器ID
+	Temperature float64   `json:"temperature"` // 温度（摄氏度）
+	RecordTime  time.Time `json:"record_time"` // 采集时间
+}
+
+// 温度传感器网关，测试时可以用本地的假网关替换
+type Gateway interface {
+	Login() error                                  // 登录网关，获取token
+	SensorList() ([]Sensor, error)                 // 查询传感器列表
+	Temperature(sensorId string) (*Reading, error) // 读取传感器当前温度
+}
+
+// 网关统一的返回结构
+type response struct {
+	Code int             `json:"code"` // 0为成功
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// FbeeCloud网关客户端
+type FbeeCloud struct {
+	Config Config
+	Client *http.Client
+
+	mutex sync.Mutex
+	token string
+}
+
+// 读取配置文件
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(file, &config); err != nil {
+		return config, fmt.Errorf("fbeecloud: parse %s error: %s", path, err)
+	}
+	if config.Url == "" || config.DevKey == "" {
+		return config, fmt.Errorf("fbeecloud: Url and DevKey are required in %s", path)
+	}
+	return config, nil
+}
+
+// 根据配置创建网关客户端，登录会在第一次请求时进行
+func New(config Config) *FbeeCloud {
+	return &FbeeCloud{
+		Config: config,
+		Client: &http.Client{Timeout: RequestTimeout},
+	}
+}
+
+// 从默认配置文件初始化网关客户端
+func InitFbeeCloud() (*FbeeCloud, error) {
+	config, err := LoadConfig(ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	return New(config), nil
+}
+
+// 拼接网关接口地址，Url未带协议时默认使用http
+func (f *FbeeCloud) endpoint(path string) string {
+	base := f.Config.Url
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	base = strings.TrimRight(base, "/")
+	if f.Config.Port != "" {
+		base += ":" + f.Config.Port
+	}
+	return base + path
+}
+
+// 登录网关，获取token
+func (f *FbeeCloud) Login() error {
+	body, err := json.Marshal(map[string]string{"devKey": f.Config.DevKey})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, f.endpoint("/api/login"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	var data struct {
+		Token string `json:"token"`
+	}
+	if err := f.do(req, &data); err != nil {
+		return err
+	}
+	if data.Token == "" {
+		return errors.New("fbeecloud: login returned empty token")
+	}
+
+	f.mutex.Lock()
+	f.token = data.Token
+	f.mutex.Unlock()
+	return nil
+}
+
+// 查询传感器列表
+func (f *FbeeCloud) SensorList() ([]Sensor, error) {
+	sensors := make([]Sensor, 0)
+	if err := f.get("/api/sensors", &sensors); err != nil {
+		return nil, err
+	}
+	return sensors, nil
+}
+
+// 读取传感器当前温度
+func (f *FbeeCloud) Temperature(sensorId string) (*Reading, error) {
+	if sensorId == "" {
+		return nil, errors.New("fbeecloud: sensor id is required")
+	}
+
+	var data struct {
+		Temperature float64 `json:"temperature"`
+		Time        int64   `json:"time"` // 采集时间（毫秒时间戳）
+	}
+	if err := f.get("/api/sensors/"+url.PathEscape(sensorId)+"/temperature", &data); err != nil {
+		return nil, err
+	}
+
+	reading := &Reading{
+		SensorId:    sensorId,
+		Temperature: data.Temperature,
+		RecordTime:  time.Unix(data.Time/1000, data.Time%1000*int64(time.Millisecond)),
+	}
+	if data.Time == 0 {
+		reading.RecordTime = time.Now()
+	}
+	return reading, nil
+}
+
+// 带token发起get请求，token无效时重新登录并重试一次
+func (f *FbeeCloud) get(path string, data interface{}) error {
+	for retried := false; ; retried = true {
+		f.mutex.Lock()
+		token := f.token
+		f.mutex.Unlock()
+
+		if token == "" {
+			if err := f.Login(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		req, err := http.NewRequest(http.MethodGet, f.endpoint(path), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", token)
+
+		err = f.do(req, data)
+		if err == ErrUnauthorized && !retried {
+			f.mutex.Lock()
+			f.token = ""
+			f.mutex.Unlock()
+			continue
+		}
+		return err
+	}
+}
+
+// 发起请求并解析网关返回的数据
+func (f *FbeeCloud) do(req *http.Request, data interface{}) error {
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrUnauthorized
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fbeecloud: %s %s returned %d: %s", req.Method, req.URL.Path, resp.StatusCode, body)
+	}
+
+	var result response
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("fbeecloud: unmarshal response error: %s", err)
+	}
+	switch result.Code {
+	case 0:
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	default:
+		return fmt.Errorf("fbeecloud: %s %s failed: %d %s", req.Method, req.URL.Path, result.Code, result.Msg)
+	}
+
+	if data == nil || len(result.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(result.Data, data)
+}
@@ -0,0 +1,142 @@
+package fbeecloud
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 本地假网关，模拟FbeeCloud的登录、传感器列表和温度接口
+type fakeGateway struct {
+	mutex  sync.Mutex
+	token  string
+	logins int
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	write := func(code int, msg string, data interface{}) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "msg": msg, "data": data})
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if r.URL.Path == "/api/login" {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["devKey"] != "1" {
+			write(1, "invalid devKey", nil)
+			return
+		}
+		g.logins++
+		g.token = "token-" + strings.Repeat("x", g.logins)
+		write(0, "", map[string]string{"token": g.token})
+		return
+	}
+
+	if r.Header.Get("Authorization") != g.token || g.token == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/api/sensors":
+		write(0, "", []Sensor{{Id: "s1", Name: "冷藏车1号", Online: true}})
+	case r.URL.Path == "/api/sensors/s1/temperature":
+		write(0, "", map[string]interface{}{"temperature": -1.5, "time": 1633017600000})
+	default:
+		write(404, "sensor not found", nil)
+	}
+}
+
+func newTestClient(t *testing.T) (*FbeeCloud, *fakeGateway) {
+	gateway := new(fakeGateway)
+	server := httptest.NewServer(gateway)
+	t.Cleanup(server.Close)
+
+	// 端口与地址分开配置，与fbeecloud.json保持一致
+	index := strings.LastIndex(server.URL, ":")
+	return New(Config{Url: server.URL[:index], Port: server.URL[index+1:], DevKey: "1"}), gateway
+}
+
+// 能读取配置文件
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigPath)
+	_ = ioutil.WriteFile(path, []byte(`{"Url": "127.0.0.1", "Port": "8082", "DevKey": "1"}`), 0644)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if New(config).endpoint("/api/login") != "http://127.0.0.1:8082/api/login" {
+		t.Fatalf("unexpected endpoint: %s", New(config).endpoint("/api/login"))
+	}
+
+	_ = ioutil.WriteFile(path, []byte(`{"Url": "127.0.0.1"}`), 0644)
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("expected error for missing DevKey")
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+}
+
+// 首次请求时自动登录，并能查询传感器列表
+func TestSensorList(t *testing.T) {
+	client, gateway := newTestClient(t)
+
+	sensors, err := client.SensorList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sensors) != 1 || sensors[0].Id != "s1" || gateway.logins != 1 {
+		t.Fatalf("unexpected sensors %+v, logins %d", sensors, gateway.logins)
+	}
+}
+
+// 能读取温度，token过期后会重新登录
+func TestTemperature(t *testing.T) {
+	client, gateway := newTestClient(t)
+	if err := client.Login(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟token过期
+	gateway.mutex.Lock()
+	gateway.token = "expired"
+	gateway.mutex.Unlock()
+
+	reading, err := client.Temperature("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reading.Temperature != -1.5 || reading.SensorId != "s1" || reading.RecordTime.Unix() != 1633017600 {
+		t.Fatalf("unexpected reading %+v", reading)
+	}
+	if gateway.logins != 2 {
+		t.Fatalf("expected re-login, logins %d", gateway.logins)
+	}
+
+	if _, err := client.Temperature("s2"); err == nil {
+		t.Fatal("expected error for unknown sensor")
+	}
+}
+
+// 设备密钥错误时登录失败
+func TestLoginFailed(t *testing.T) {
+	client, _ := newTestClient(t)
+	client.Config.DevKey = "wrong"
+	if err := client.Login(); err == nil {
+		t.Fatal("expected login error")
+	}
+	if _, err := client.SensorList(); err == nil {
+		t.Fatal("expected error without login")
+	}
+}
@@ -0,0 +1,9 @@
+package lib
+
+// 账户角色，与链码中账户的角色一致
+const (
+	RoleSupplier  = "supplier"  // 供货商
+	RoleCarrier   = "carrier"   // 物流商
+	RoleBuyer     = "buyer"     // 买家
+	RoleRegulator = "regulator" // 监管方
+)
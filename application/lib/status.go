@@ -0,0 +1,46 @@
+package lib
+
+// 订单状态码，与链码中存储的状态一致
+const (
+	StatusNew        = "New"        // 新建
+	StatusProcessing = "Processing" // 运送中
+	StatusDone       = "Done"       // 完成
+	StatusCanceled   = "Canceled"   // 取消
+)
+
+// 默认语言
+const DefaultLanguage = "zh"
+
+// 各语言下订单状态的显示名称
+var StatusLabels = map[string]map[string]string{
+	"zh": {
+		StatusNew:        "新建",
+		StatusProcessing: "运送中",
+		StatusDone:       "完成",
+		StatusCanceled:   "取消",
+	},
+	"en": {
+		StatusNew:        "New",
+		StatusProcessing: "Processing",
+		StatusDone:       "Done",
+		StatusCanceled:   "Canceled",
+	},
+}
+
+// 判断是否为合法的订单状态
+func IsValidStatus(status string) bool {
+	_, ok := StatusLabels[DefaultLanguage][status]
+	return ok
+}
+
+// 返回订单状态在指定语言下的显示名称，未知的语言使用默认语言，未知的状态原样返回
+func StatusLabel(status, lang string) string {
+	labels, ok := StatusLabels[lang]
+	if !ok {
+		labels = StatusLabels[DefaultLanguage]
+	}
+	if label, ok := labels[status]; ok {
+		return label
+	}
+	return status
+}
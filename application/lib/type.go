@@ -7,32 +7,70 @@ import (
 
 // 商品
 type Commodity struct {
-	Name     string `json:"name"` // 商品名
-	Id       string `json:"id"`
-	Location string `json:"location"` //产地
-	//LowTemperature  float64 `json:"lowTemperature"`  //最低温
-	//HighTemperature float64 `json:"highTemperature"` //最高温
-	Price   float64 `json:"price"` //单价
-	OwnerId string  `json:"owner"` //所有者
+	Name            string  `json:"name"` // 商品名
+	Id              string  `json:"id"`
+	Location        string  `json:"location"`        //产地
+	LowTemperature  float64 `json:"lowTemperature"`  //最低温
+	HighTemperature float64 `json:"highTemperature"` //最高温
+	Price           float64 `json:"price"`           //单价
+	OwnerId         string  `json:"owner"`           //所有者
+}
+
+// 结算明细
+type Settlement struct {
+	TotalPrice    float64 `json:"totalPrice"`    //应付总额
+	LowDeviation  float64 `json:"lowDeviation"`  //最低温度偏差值
+	HighDeviation float64 `json:"highDeviation"` //最高温度偏差值
+	Deduction     float64 `json:"deduction"`     //扣款
+	Payment       float64 `json:"payment"`       //买家实付金额
+	Freight       float64 `json:"freight"`       //物流商收入
+	SellerIncome  float64 `json:"sellerIncome"`  //供货商收入
+}
+
+// 账户
+type Account struct {
+	Id          string  `json:"id"`
+	Name        string  `json:"name"`        //账户名
+	Balance     float64 `json:"balance"`     //余额
+	Role        string  `json:"role"`        //角色
+	MspId       string  `json:"mspId"`       //账户所属组织的MSP ID
+	Deactivated bool    `json:"deactivated"` //是否已注销
 }
 
 // 温度
-//type Temperature struct {
-//	Temperature float64   `json:"temperature"`
-//	RecordTime  time.Time `json:"record_time"`
-//}
+type Temperature struct {
+	Temperature float64   `json:"temperature"`
+	RecordTime  time.Time `json:"record_time"`
+	SensorId    string    `json:"sensor_id"`
+}
 
 // 订单
 type Order struct {
-	Commodity *Commodity `json:"commodity"` //商品
-	//DeliverAddress       string            `json:"deliverAddress"` //配送地址
-	Id        string    `json:"id"`
-	OrderTime time.Time `json:"orderTime"`
-	//DeliverTime          time.Time         `json:"deliverTime"`          //配送时间
-	//Quantity             int64             `json:"quantity"`             //数量
-	Status string `json:"status"` //订单状态
-	//TemperatureVariation []*Temperature    `json:"temperatureVariation"` //温度变化
-	BuyerId       string            `json:"buyer"`  //买家
-	SellerId      string            `json:"seller"` //卖家
-	TransactionId fab.TransactionID `json:"transaction_id"`
+	Commodity            *Commodity        `json:"commodity"`      //商品
+	DeliverAddress       string            `json:"deliverAddress"` //配送地址
+	Id                   string            `json:"id"`
+	OrderTime            time.Time         `json:"orderTime"`
+	DeliverTime          time.Time         `json:"deliverTime"`          //预计送达时间
+	Quantity             int64             `json:"quantity"`             //数量
+	Status               string            `json:"status"`               //订单状态，返回前端时为显示名称
+	StatusCode           string            `json:"statusCode"`           //订单状态码
+	PreviousStatus       string            `json:"previousStatus"`       //上一个订单状态
+	StatusTime           time.Time         `json:"statusTime"`           //状态变更时间
+	SensorId             string            `json:"sensorId"`             //温度传感器ID
+	TemperatureVariation []*Temperature    `json:"temperatureVariation"` //温度变化
+	BuyerId              string            `json:"buyer"`                //买家
+	SellerId             string            `json:"seller"`               //卖家
+	CarrierId            string            `json:"carrier"`              //物流商
+	FreightType          string            `json:"freightType"`          //物流费计算方式
+	Freight              float64           `json:"freight"`              //物流费百分比或固定运费
+	Settlement           *Settlement       `json:"settlement"`           //结算明细
+	TransactionId        fab.TransactionID `json:"transaction_id"`       //创建订单的交易ID
+	Transactions         []*Transaction    `json:"transactions"`         //订单的所有交易，按上链顺序
+}
+
+// 订单相关的交易，从区块的链码事件同步
+type Transaction struct {
+	TxId  fab.TransactionID `json:"tx_id"`
+	Event string            `json:"event"` //链码事件类型，如OrderStatusChanged
+	Block uint64            `json:"block"` //所在区块，尚未同步时为0
 }
@@ -1,36 +1,108 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"gdzce.cn/perishable-food/application/auth"
 	"gdzce.cn/perishable-food/application/blockchain"
 	"gdzce.cn/perishable-food/application/controller"
+	"gdzce.cn/perishable-food/application/events"
 	"gdzce.cn/perishable-food/application/fbeecloud"
+	"gdzce.cn/perishable-food/application/lib"
 	"gdzce.cn/perishable-food/application/repository"
+	"gdzce.cn/perishable-food/application/updater"
+	"gdzce.cn/perishable-food/application/wallet"
 	"github.com/gin-gonic/gin"
 )
 
 const (
-	TransactionRecordFileName = "transactionRecord.json" // 存储 [{orderId，txid}] 数组的json文件名
+	TransactionRecordFileName = "transactionRecord.json" // 缓存订单id与txid对应关系的json文件名
 )
 
+// 路由及其访问策略
+type route struct {
+	method  string
+	path    string
+	handler gin.HandlerFunc
+	policy  controller.Policy
+}
+
 // 设置路由
 func setupRouter() *gin.Engine {
 	// gin.SetMode(gin.ReleaseMode)			// 开启生产模式（关闭DEBUG模式）
 	router := gin.Default()
 
 	// 加载中间件
-	router.Use(controller.CORS)         // 先加载跨域模块（便于开发）
+	router.Use(controller.CORS)         // 先加载跨域模块，只允许配置的来源
 	router.Use(controller.FixVueRouter) // 修复前端内部路由问题
+	router.Use(controller.Authenticate) // 解析登录令牌
+
+	// 路由表，修改数据的路由需要登录，并检查请求中的账户、订单和商品是否属于登录用户
+	var (
+		public    = controller.Policy{Public: true}
+		admin     = controller.Policy{Roles: []string{auth.RoleAdmin}}
+		supplier  = lib.RoleSupplier
+		carrier   = lib.RoleCarrier
+		buyer     = lib.RoleBuyer
+		regulator = lib.RoleRegulator
+	)
+
+	routes := []route{
+		{"POST", "/login", controller.Login, public},
+		{"GET", "/commodityList", controller.CommodityList, public},
+
+		// 订单和账户按调用者证书过滤，必须登录，不能以默认的管理员身份查询
+		{"GET", "/orderList", controller.OrderList, controller.Policy{}},
+		{"POST", "/accountList", controller.AccountList, controller.Policy{}},
+
+		// 事件按登录用户的账户和角色过滤
+		{"GET", "/events", controller.Events, controller.Policy{}},
+
+		// 商品由供货商创建，订单由买家创建，且所购商品必须属于卖家
+		{"POST", "/createCommodity", controller.CreateCommodity, controller.Policy{
+			Roles:  []string{supplier},
+			Checks: []controller.Check{controller.Self("owner")},
+		}},
+		{"POST", "/createOrder", controller.CreateOrder, controller.Policy{
+			Roles:  []string{buyer},
+			Checks: []controller.Check{controller.Self("buyer"), controller.CommodityOwner("commodity_id", "seller")},
+		}},
 
-	// 定义路由（当地址匹配时，调用相应函数）
-	router.POST("/createCommodity", controller.CreateCommodity)
-	router.POST("/createOrder", controller.CreateOrder)
-	router.GET("/commodityList", controller.CommodityList)
-	router.GET("/orderList", controller.OrderList)
-	router.POST("/accountList", controller.AccountList)
-	router.POST("/updateOrderTemperature", controller.UpdateOrderTemperature)
-	router.POST("/updateOrderStatus", controller.UpdateOrderStatus)
+		// 账户由管理员创建和注销，账户名可以由本人修改
+		{"POST", "/createAccount", controller.CreateAccount, admin},
+		{"POST", "/updateAccount", controller.UpdateAccount, controller.Policy{
+			Roles:  []string{auth.RoleAdmin, supplier, carrier, buyer, regulator},
+			Checks: []controller.Check{controller.Self("id")},
+		}},
+		{"POST", "/deactivateAccount", controller.DeactivateAccount, admin},
+
+		// 温度只能由订单的物流商上传，订单状态只能由订单的参与方更新
+		{"POST", "/updateOrderTemperature", controller.UpdateOrderTemperature, controller.Policy{
+			Roles:  []string{carrier},
+			Checks: []controller.Check{controller.Self("operator"), controller.OrderParty("order_id")},
+		}},
+		{"POST", "/updateOrderStatus", controller.UpdateOrderStatus, controller.Policy{
+			Roles:  []string{supplier, carrier, buyer},
+			Checks: []controller.Check{controller.Self("operator"), controller.OrderParty("order_id")},
+		}},
+
+		// 用户身份管理
+		{"POST", "/admin/registerUser", controller.RegisterUser, admin},
+		{"POST", "/admin/enrollUser", controller.EnrollUser, admin},
+		{"POST", "/admin/reenrollUser", controller.ReenrollUser, admin},
+		{"POST", "/admin/revokeUser", controller.RevokeUser, admin},
+	}
+
+	// 定义路由（当地址匹配时，调用相应函数），按访问策略校验登录用户的角色和数据归属
+	for _, route := range routes {
+		router.Handle(route.method, route.path, controller.Authorize(route.policy), route.handler)
+	}
 
 	// 静态文件路由
 	router.StaticFS("/web/", http.Dir("./public/"))
@@ -40,26 +112,145 @@ func setupRouter() *gin.Engine {
 }
 
 func main() {
-	// 初始化fabric sdk
-	blockchain.Init()
+	// 读取账本配置，初始化fabric sdk
+	config, err := blockchain.InitConfig()
+	if err != nil {
+		panic(err)
+	}
+	if err := blockchain.Init(config); err != nil {
+		panic(err)
+	}
+
+	// 初始化登录令牌的签名密钥
+	controller.Tokens, err = auth.InitSigner()
+	if err != nil {
+		panic(err)
+	}
 
-	// 加载存储[{orderId，txid}]的json文件
-	repository.TransactionRecordList.FilePath = TransactionRecordFileName
-	_ = repository.TransactionRecordList.LoadTransactionRecords()
+	// 打开钱包并连接组织的CA，后台任务以账户登记的身份上链
+	if err := initWallet(); err != nil {
+		fmt.Println("初始化钱包失败，没有登记身份的账户不启动温度更新器：", err)
+	}
+
+	// 打开存储[{orderId，txid}]的缓存，打开失败时只保存在内存中
+	if err := initRecords(config); err != nil {
+		fmt.Println("打开交易记录缓存失败，从区块0重新同步：", err)
+	}
+
+	// 监听默认合约的区块和链码事件，推送给客户端，并同步订单的交易记录
+	if err := initEvents(config); err != nil {
+		fmt.Println("启动事件监听失败：", err)
+	}
 
 	// 初始化fbeeCloud
-	var err error
 	controller.Fbee, err = fbeecloud.InitFbeeCloud()
 	if err != nil {
 		panic(err)
 	}
 
+	// 启动温度更新器，并恢复账本中运送中订单的温度获取
+	controller.TemperatureUpdaters = updater.NewManager(controller.Fbee, controller.SubmitTemperature, controller.TemperatureUpdatersInterval)
+	if err := controller.RestoreTemperatureUpdaters(); err != nil {
+		fmt.Println("恢复温度更新器失败：", err)
+	}
+
 	// 加载路由
 	router := setupRouter()
 
-	// 启动http服务器
-	err = router.Run() // listen and serve on 0.0.0.0:8080
-	if err != nil {
+	// 启动http服务器，收到退出信号后停止服务并关闭交易记录存储
+	server := &http.Server{Addr: listenAddr(), Handler: router}
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			fmt.Println("停止http服务器失败：", err)
+		}
+	}()
+	err = server.ListenAndServe() // listen and serve on 0.0.0.0:8080
+	if err := repository.TransactionRecordList.Close(); err != nil {
+		fmt.Println("关闭交易记录存储失败：", err)
+	}
+	if err != nil && err != http.ErrServerClosed {
 		panic(err)
 	}
 }
+
+// http服务器的监听地址，与gin一致，默认8080端口，可用环境变量PORT修改
+func listenAddr() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
+	}
+	return ":8080"
+}
+
+// 启动事件监听，Fabric账本从检查点继续，本地模拟账本每次从头开始
+func initEvents(config blockchain.Config) error {
+	contract := config.DefaultContract()
+	source, err := blockchain.ContractEvents(contract.Name)
+	if err != nil {
+		return err
+	}
+	listener := &events.Listener{Source: source, Hub: events.NewHub()}
+	if config.Backend != blockchain.BackendMock {
+		listener.Checkpoint = events.NewCheckpoint(contract.Name)
+	}
+	controller.EventHub = listener.Hub
+	go listener.Run(context.Background())
+
+	// 交易记录单独订阅，从缓存记录的区块继续同步
+	records := &events.Listener{
+		Source:   source,
+		From:     repository.TransactionRecordList.NextBlock(),
+		Handlers: []events.Handler{events.RecordTransactions(repository.TransactionRecordList)},
+	}
+	go records.Run(context.Background())
+	return nil
+}
+
+// 按环境变量RECORD_STORE打开交易记录存储，本地模拟账本每次启动都是新账本，不使用缓存
+func initRecords(config blockchain.Config) error {
+	kind, path := os.Getenv(repository.StoreEnv), TransactionRecordFileName
+	if kind == repository.StoreBolt {
+		path = repository.BoltPath
+	}
+	if config.Backend == blockchain.BackendMock {
+		kind, path = repository.StoreJSON, ""
+	}
+	store, err := repository.OpenStore(kind, path)
+	if err != nil {
+		return err
+	}
+	repository.TransactionRecordList = store
+	return nil
+}
+
+// 初始化钱包和CA
+func initWallet() error {
+	if blockchain.SDK == nil {
+		return fmt.Errorf("%s ledger backend has no CA", blockchain.Current.Backend)
+	}
+	w, err := wallet.InitWallet()
+	if err != nil {
+		return err
+	}
+	controller.CA, err = wallet.NewCA(blockchain.SDK, blockchain.Current.Org, w)
+	if err != nil {
+		return err
+	}
+
+	// 账户没有登记身份时返回错误，不以默认身份上链
+	controller.AccountIdentity = func(accountId string) (*blockchain.Identity, error) {
+		id, err := controller.CA.AccountIdentity(accountId)
+		if err == wallet.ErrNotFound {
+			return nil, fmt.Errorf("account %s has no enrolled identity: %w", accountId, err)
+		}
+		if id != nil {
+			id.AccountId = accountId
+		}
+		return id, err
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var BoltPath = "./data/transactionRecord.db" // 默认数据库文件路径
+
+var (
+	recordsBucket = []byte("records") // 交易记录，键为订单id、分隔符和序号，同一订单的记录相邻
+	metaBucket    = []byte("meta")    // 同步进度
+	nextBlockKey  = []byte("next_block")
+)
+
+// 基于bbolt的交易记录存储，同一时间只能由一个进程打开
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// 打开或创建数据库文件，文件被其他进程占用时1秒后返回错误
+func OpenBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// 订单记录的键前缀
+func orderPrefix(orderId string) []byte {
+	return append([]byte(orderId), 0)
+}
+
+// 在事务中记录一笔交易
+func (s *BoltStore) push(tx *bolt.Tx, tr TransactionRecord) error {
+	bucket := tx.Bucket(recordsBucket)
+	prefix := orderPrefix(tr.OrderId)
+	c := bucket.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var item TransactionRecord
+		if err := json.Unmarshal(v, &item); err != nil {
+			return err
+		}
+		if item.TxID != tr.TxID {
+			continue
+		}
+		if tr.Event != "" {
+			item.Event = tr.Event
+		}
+		if tr.Block != 0 {
+			item.Block = tr.Block
+		}
+		return s.put(bucket, k, item)
+	}
+
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := make([]byte, len(prefix)+8)
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], seq)
+	return s.put(bucket, key, tr)
+}
+
+func (s *BoltStore) put(bucket *bolt.Bucket, key []byte, tr TransactionRecord) error {
+	value, err := json.Marshal(tr)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, value)
+}
+
+func (s *BoltStore) Push(trs ...TransactionRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, tr := range trs {
+			if err := s.push(tx, tr); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 交易记录和同步进度在同一事务中写入
+func (s *BoltStore) Sync(block uint64, trs []TransactionRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if block < s.nextBlock(tx) {
+			return nil
+		}
+		for _, tr := range trs {
+			tr.Block = block
+			if err := s.push(tx, tr); err != nil {
+				return err
+			}
+		}
+		next := make([]byte, 8)
+		binary.BigEndian.PutUint64(next, block+1)
+		return tx.Bucket(metaBucket).Put(nextBlockKey, next)
+	})
+}
+
+func (s *BoltStore) nextBlock(tx *bolt.Tx) uint64 {
+	if next := tx.Bucket(metaBucket).Get(nextBlockKey); len(next) == 8 {
+		return binary.BigEndian.Uint64(next)
+	}
+	return 0
+}
+
+// 读取失败时返回0，从区块0重新同步
+func (s *BoltStore) NextBlock() (next uint64) {
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		next = s.nextBlock(tx)
+		return nil
+	})
+	return next
+}
+
+func (s *BoltStore) FindByOrderId(orderId string) (trs []TransactionRecord, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		prefix := orderPrefix(orderId)
+		c := tx.Bucket(recordsBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var tr TransactionRecord
+			if err := json.Unmarshal(v, &tr); err != nil {
+				return err
+			}
+			trs = append(trs, tr)
+		}
+		return nil
+	})
+	return trs, err
+}
+
+func (s *BoltStore) ForEach(fn func(tr TransactionRecord) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).ForEach(func(k, v []byte) error {
+			var tr TransactionRecord
+			if err := json.Unmarshal(v, &tr); err != nil {
+				return err
+			}
+			return fn(tr)
+		})
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
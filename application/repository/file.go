@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const Backups = 3 // JSON文件保留的备份数，备份文件为<文件名>.1到<文件名>.3，1为最新
+
+var BackupInterval = time.Hour // 两次备份的最短间隔
+
+// 先写入同一目录的临时文件并刷盘，再重命名覆盖原文件，写入中途崩溃时原文件保持完整
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (e error) {
+	tmp, e := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if e != nil {
+		return e
+	}
+	defer func() {
+		if e != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, e = tmp.Write(data); e != nil {
+		_ = tmp.Close()
+		return e
+	}
+	if e = tmp.Sync(); e != nil {
+		_ = tmp.Close()
+		return e
+	}
+	if e = tmp.Close(); e != nil {
+		return e
+	}
+	if e = os.Chmod(tmp.Name(), perm); e != nil {
+		return e
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 第n个备份的路径
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// 距最新的备份超过BackupInterval时轮转备份：删除最旧的备份，其余顺延，当前文件成为备份1
+func rotateBackups(path string) error {
+	current, e := os.Stat(path)
+	if os.IsNotExist(e) {
+		return nil
+	} else if e != nil {
+		return e
+	}
+	if latest, e := os.Stat(backupPath(path, 1)); e == nil && time.Since(latest.ModTime()) < BackupInterval {
+		return nil
+	}
+
+	for n := Backups; n > 1; n-- {
+		if e := os.Rename(backupPath(path, n-1), backupPath(path, n)); e != nil && !os.IsNotExist(e) {
+			return e
+		}
+	}
+	// 硬链接当前文件，随后重命名覆盖时备份不受影响；不支持硬链接时复制
+	if e := os.Link(path, backupPath(path, 1)); e == nil {
+		return nil
+	}
+	return copyFile(backupPath(path, 1), path, current.Mode())
+}
+
+func copyFile(dst, src string, perm os.FileMode) error {
+	in, e := os.Open(src)
+	if e != nil {
+		return e
+	}
+	defer in.Close()
+	out, e := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if e != nil {
+		return e
+	}
+	if _, e = io.Copy(out, in); e != nil {
+		_ = out.Close()
+		return e
+	}
+	return out.Close()
+}
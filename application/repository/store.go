@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"fmt"
+)
+
+// 交易记录存储的类型
+const (
+	StoreEnv  = "RECORD_STORE" // 选择交易记录存储的环境变量，为空时使用json
+	StoreJSON = "json"         // JSON文件，每次写入整个文件，适合少量订单
+	StoreBolt = "bolt"         // 嵌入式键值数据库，按订单id索引，写入在事务中完成
+)
+
+// 订单id与交易id对应关系的存储
+type Store interface {
+	// 记录交易，已有同一订单同一交易的记录时只补全事件和区块
+	Push(trs ...TransactionRecord) error
+	// 记录从区块block同步的交易并更新同步进度，已同步过的区块直接跳过
+	Sync(block uint64, trs []TransactionRecord) error
+	// 下一个要同步的区块
+	NextBlock() uint64
+	// 订单的所有交易，按上链顺序排列
+	FindByOrderId(orderId string) ([]TransactionRecord, error)
+	// 按订单遍历所有记录，同一订单的记录按上链顺序
+	ForEach(fn func(tr TransactionRecord) error) error
+	Close() error
+}
+
+var TransactionRecordList Store = new(TransactionRecords) // 订单id与交易id对应关系，默认只保存在内存中
+
+// 打开指定类型的存储，JSON文件路径为空时只保存在内存中
+func OpenStore(kind, path string) (Store, error) {
+	switch kind {
+	case "", StoreJSON:
+		trs := new(TransactionRecords)
+		if err := trs.Open(path); err != nil {
+			return nil, err
+		}
+		return trs, nil
+	case StoreBolt:
+		return OpenBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unknown record store %s", kind)
+	}
+}
+
+// 将src中的记录和同步进度复制到dst，返回复制的记录数
+func Migrate(dst, src Store) (int, error) {
+	var trs []TransactionRecord
+	if err := src.ForEach(func(tr TransactionRecord) error {
+		trs = append(trs, tr)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	if err := dst.Push(trs...); err != nil {
+		return 0, err
+	}
+	if next := src.NextBlock(); next > dst.NextBlock() {
+		if err := dst.Sync(next-1, nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(trs), nil
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+)
+
+// 两种存储的记录、同步和查询行为一致，重新打开后数据仍在
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	for _, c := range []struct {
+		kind, path string
+	}{
+		{StoreJSON, filepath.Join(dir, "transactionRecord.json")},
+		{StoreBolt, filepath.Join(dir, "data", "transactionRecord.db")},
+	} {
+		store, err := OpenStore(c.kind, c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Push(TransactionRecord{OrderId: "1", TxID: "tx1", Event: "OrderCreated"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Sync(3, []TransactionRecord{
+			{OrderId: "1", TxID: "tx1", Event: "OrderCreated"},
+			{OrderId: "10", TxID: "tx2", Event: "OrderCreated"},
+			{OrderId: "1", TxID: "tx3", Event: "OrderStatusChanged"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		// 已同步的区块跳过
+		if err := store.Sync(2, []TransactionRecord{{OrderId: "1", TxID: "tx4"}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		store, err = OpenStore(c.kind, c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trs, err := store.FindByOrderId("1")
+		if err != nil || len(trs) != 2 || trs[0].TxID != "tx1" || trs[0].Block != 3 || trs[1].Event != "OrderStatusChanged" {
+			t.Fatalf("%s: unexpected records %+v, %v", c.kind, trs, err)
+		}
+		if store.NextBlock() != 4 {
+			t.Fatalf("%s: expected next block 4, got %d", c.kind, store.NextBlock())
+		}
+		_ = store.Close()
+	}
+}
+
+// 旧版本的JSON文件迁移到bbolt数据库，迁移后从区块0同步
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "transactionRecord.json")
+	if err := ioutil.WriteFile(src, []byte(`[{"order_id":"1","tx_id":"tx1"},{"order_id":"2","tx_id":"tx2"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srcStore, err := OpenStore(StoreJSON, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstStore, err := OpenBoltStore(filepath.Join(dir, "transactionRecord.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dstStore.Close()
+
+	if n, err := Migrate(dstStore, srcStore); err != nil || n != 2 {
+		t.Fatalf("expected 2 records migrated, got %d, %v", n, err)
+	}
+	if trs, _ := dstStore.FindByOrderId("2"); len(trs) != 1 || trs[0].TxID != "tx2" || dstStore.NextBlock() != 0 {
+		t.Fatalf("unexpected records %+v", trs)
+	}
+	// 再次迁移不产生重复记录，源文件保持原样
+	_ = srcStore.Close()
+	if _, err := Migrate(dstStore, srcStore); err != nil {
+		t.Fatal(err)
+	}
+	if trs, _ := dstStore.FindByOrderId("1"); len(trs) != 1 {
+		t.Fatalf("duplicated records %+v", trs)
+	}
+	if data, _ := ioutil.ReadFile(src); string(data) != `[{"order_id":"1","tx_id":"tx1"},{"order_id":"2","tx_id":"tx2"}]` {
+		t.Fatalf("source file changed: %s", data)
+	}
+}
+
+// 并发记录和查询，配合-race检查
+func TestStoreConcurrent(t *testing.T) {
+	store, err := OpenStore(StoreJSON, filepath.Join(t.TempDir(), "transactionRecord.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			orderId := strconv.Itoa(i)
+			_ = store.Push(TransactionRecord{OrderId: orderId, TxID: fab.TransactionID("tx" + orderId)})
+			_ = store.Sync(uint64(i), []TransactionRecord{{OrderId: orderId, TxID: "sync" + fab.TransactionID(orderId)}})
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = store.FindByOrderId(strconv.Itoa(i))
+			_ = store.NextBlock()
+		}(i)
+	}
+	wg.Wait()
+	// 区块乱序同步时较早的区块被跳过，Push的记录都在
+	for i := 0; i < 10; i++ {
+		if trs, _ := store.FindByOrderId(strconv.Itoa(i)); len(trs) == 0 || trs[0].TxID != fab.TransactionID("tx"+strconv.Itoa(i)) {
+			t.Fatalf("unexpected records of order %d: %+v", i, trs)
+		}
+	}
+	if store.NextBlock() != 10 {
+		t.Fatalf("expected next block 10, got %d", store.NextBlock())
+	}
+}
+
+// 写入时轮转备份，文件损坏时移走并从最新的备份恢复
+func TestRecordsFile(t *testing.T) {
+	BackupInterval = 0
+	defer func() { BackupInterval = time.Hour }()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "transactionRecord.json")
+
+	trs := new(TransactionRecords)
+	if err := trs.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	for block := uint64(0); block < 5; block++ {
+		if err := trs.Sync(block, []TransactionRecord{{OrderId: "1", TxID: fab.TransactionID(strconv.FormatUint(block, 10))}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, _ := filepath.Glob(path + "*")
+	if len(files) != 1+Backups {
+		t.Fatalf("expected file and %d backups, got %v", Backups, files)
+	}
+
+	// 写入中途崩溃留下的空文件视为损坏
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := &TransactionRecords{FilePath: path}
+	if err := reloaded.LoadTransactionRecords(); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt, got %v", err)
+	}
+	if err := reloaded.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".corrupt"); err != nil {
+		t.Fatal(err)
+	}
+	// 备份1为写入区块4之前的文件
+	if reloaded.NextBlock() != 4 {
+		t.Fatalf("expected restored next block 4, got %d", reloaded.NextBlock())
+	}
+	if restored, err := readRecordsFile(path); err != nil || restored.NextBlock != 4 {
+		t.Fatalf("restored file not written: %+v, %v", restored, err)
+	}
+}
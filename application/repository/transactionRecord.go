@@ -2,83 +2,267 @@ package repository
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"sync"
 
 	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
 )
 
+var ErrCorrupt = errors.New("transaction records file is corrupt") // 缓存文件无法解析
+
 // transactionRecords中单个元素的结构体 {订单id，txid}
 type TransactionRecord struct {
-	OrderId string            `json:"order_id" bson:"order_id"` // orderId 订单Id
-	TxID    fab.TransactionID `json:"tx_id" bson:"tx_id"`       // txid 区块链的transactionId
+	OrderId string            `json:"order_id" bson:"order_id"`               // orderId 订单Id
+	TxID    fab.TransactionID `json:"tx_id" bson:"tx_id"`                     // txid 区块链的transactionId
+	Event   string            `json:"event,omitempty" bson:"event,omitempty"` // 交易的链码事件类型，如OrderCreated
+	Block   uint64            `json:"block,omitempty" bson:"block,omitempty"` // 交易所在区块，尚未从区块同步时为0
+}
+
+// 缓存文件的内容
+type transactionRecordsFile struct {
+	NextBlock uint64              `json:"next_block"` // 下一个要同步的区块
+	Records   []TransactionRecord `json:"records"`
 }
 
-// 存储 [{orderId，txid}] 数组，用于持久化存储订单id与txid的对应关系
-// 生成订单时会由前端传入一个订单号，将该交易发送到区块链后会返回一个区块链的txid
+// 以JSON文件存储 [{orderId，txid}] 数组，记录订单id与该订单所有交易txid的对应关系
 // 在订单列表中需要展示，从区块链查询的数据只有orderId,所以需要找到它对应的txid
+// 对应关系从区块中的链码事件同步，JSON文件只是缓存，删除后会从区块0重新同步
 type TransactionRecords struct {
 	TxRecord []TransactionRecord
-	FilePath string
+	FilePath string // 缓存文件路径，为空时只保存在内存中，每次启动从区块0同步
+
+	mutex     sync.RWMutex
+	nextBlock uint64           // 下一个要同步的区块
+	orders    map[string][]int // 订单id在TxRecord中的下标，按交易顺序
+	dirty     bool             // Push后尚未写入文件
+}
+
+// 将TransactionRecord加进数组，已有同一订单同一交易的记录时只补全事件和区块
+// 只修改内存，由Sync或Save写入文件
+func (trs *TransactionRecords) Push(trList ...TransactionRecord) error {
+	trs.mutex.Lock()
+	defer trs.mutex.Unlock()
+	for _, tr := range trList {
+		trs.push(tr)
+	}
+	trs.dirty = trs.dirty || len(trList) > 0
+	return nil
 }
 
-// 将一个TransactionRecord加进数组
-func (trs *TransactionRecords) Push(tr TransactionRecord) {
+func (trs *TransactionRecords) push(tr TransactionRecord) {
+	trs.index()
+	for _, i := range trs.orders[tr.OrderId] {
+		if trs.TxRecord[i].TxID != tr.TxID {
+			continue
+		}
+		if tr.Event != "" {
+			trs.TxRecord[i].Event = tr.Event
+		}
+		if tr.Block != 0 {
+			trs.TxRecord[i].Block = tr.Block
+		}
+		return
+	}
 	trs.TxRecord = append(trs.TxRecord, tr)
+	trs.orders[tr.OrderId] = append(trs.orders[tr.OrderId], len(trs.TxRecord)-1)
+}
+
+// 按需建立订单id的索引
+func (trs *TransactionRecords) index() {
+	if trs.orders != nil {
+		return
+	}
+	trs.orders = make(map[string][]int)
+	for i, item := range trs.TxRecord {
+		trs.orders[item.OrderId] = append(trs.orders[item.OrderId], i)
+	}
+}
+
+// 记录从区块block同步的交易，并将同步进度和记录写入缓存文件
+// 已同步过的区块直接跳过，重复处理同一区块不会产生重复记录
+func (trs *TransactionRecords) Sync(block uint64, records []TransactionRecord) error {
+	trs.mutex.Lock()
+	defer trs.mutex.Unlock()
+	if block < trs.nextBlock {
+		return nil
+	}
+	for _, tr := range records {
+		tr.Block = block
+		trs.push(tr)
+	}
+	trs.nextBlock = block + 1
+	if trs.FilePath == "" {
+		return nil
+	}
+	return trs.save()
+}
+
+// 下一个要同步的区块
+func (trs *TransactionRecords) NextBlock() uint64 {
+	trs.mutex.RLock()
+	defer trs.mutex.RUnlock()
+	return trs.nextBlock
 }
 
 // 将本结构体内的TxRecord数组由内存写到磁盘，默认当前目录生成一个json文件
 func (trs *TransactionRecords) Save() (e error) {
-	marshal, e := json.Marshal(trs.TxRecord) // 序列化为json
+	trs.mutex.Lock()
+	defer trs.mutex.Unlock()
+	return trs.save()
+}
+
+func (trs *TransactionRecords) save() (e error) {
+	marshal, e := json.Marshal(transactionRecordsFile{NextBlock: trs.nextBlock, Records: trs.TxRecord}) // 序列化为json
 	if e != nil {
 		return e
 	}
 
-	// 写文件
-	e = ioutil.WriteFile(trs.FilePath, marshal, 0644)
+	// 轮转备份后写文件，写入中途崩溃时原文件不受影响
+	if e = rotateBackups(trs.FilePath); e != nil {
+		return e
+	}
+	e = writeFileAtomic(trs.FilePath, marshal, 0644)
 	if e != nil {
 		return e
 	}
 
+	trs.dirty = false
 	return nil
 }
 
-// 用orderId来查找对应的txid
+// 用orderId来查找对应的txid，返回该订单的第一笔交易，即创建订单的交易
 func (trs *TransactionRecords) FindOneByOrderId(orderId string) (index int, tr TransactionRecord) {
-	for index, item := range trs.TxRecord {
-		if item.OrderId == orderId {
-			return index, item
-		}
+	trs.mutex.Lock()
+	defer trs.mutex.Unlock()
+	trs.index()
+	if indexes := trs.orders[orderId]; len(indexes) > 0 {
+		return indexes[0], trs.TxRecord[indexes[0]]
 	}
 	return -1, TransactionRecord{}
 }
 
-// 根据传入的orderid，将其从数组中删除。
+// 用orderId来查找该订单的所有交易，按上链顺序排列
+func (trs *TransactionRecords) FindByOrderId(orderId string) (trList []TransactionRecord, e error) {
+	trs.mutex.Lock()
+	defer trs.mutex.Unlock()
+	trs.index()
+	for _, i := range trs.orders[orderId] {
+		trList = append(trList, trs.TxRecord[i])
+	}
+	return trList, nil
+}
+
+// 按加入顺序遍历所有记录
+func (trs *TransactionRecords) ForEach(fn func(tr TransactionRecord) error) error {
+	trs.mutex.RLock()
+	trList := append([]TransactionRecord(nil), trs.TxRecord...)
+	trs.mutex.RUnlock()
+	for _, tr := range trList {
+		if e := fn(tr); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// 关闭时写入Push后尚未写入文件的记录
+func (trs *TransactionRecords) Close() error {
+	trs.mutex.Lock()
+	defer trs.mutex.Unlock()
+	if trs.FilePath == "" || !trs.dirty {
+		return nil
+	}
+	return trs.save()
+}
+
+// 根据传入的orderid，将其第一笔交易从数组中删除。
 // 若成功删除，则返回该结构体；若不存在，则返回空。
 func (trs *TransactionRecords) DeleteOne(orderId string) (tr TransactionRecord) {
-	index, tr := trs.FindOneByOrderId(orderId)
-	if index != -1 {
-		trs.TxRecord = append(trs.TxRecord[:index], trs.TxRecord[index+1:]...)
+	trs.mutex.Lock()
+	defer trs.mutex.Unlock()
+	trs.index()
+	if indexes := trs.orders[orderId]; len(indexes) > 0 {
+		tr = trs.TxRecord[indexes[0]]
+		trs.TxRecord = append(trs.TxRecord[:indexes[0]], trs.TxRecord[indexes[0]+1:]...)
+		trs.orders = nil
 	}
 	return tr
 }
 
 // 实现String方法，用于打印日志
 func (trs *TransactionRecords) String() (s string) {
+	trs.mutex.RLock()
+	defer trs.mutex.RUnlock()
 	return fmt.Sprintf("%+v", trs.TxRecord)
 }
 
-var TransactionRecordList TransactionRecords // 订单id与交易id对应关系
-
-// 加载存储orderId与txid对应关系的JSON
+// 加载存储orderId与txid对应关系的JSON，并从其中记录的区块继续同步
+// 旧版本的文件只有[{orderId，txid}]数组，加载后从区块0重新同步；文件损坏时返回ErrCorrupt
 func (trs *TransactionRecords) LoadTransactionRecords() error {
+	trs.mutex.Lock()
+	defer trs.mutex.Unlock()
+	trs.TxRecord, trs.nextBlock, trs.orders, trs.dirty = nil, 0, nil, false
+
+	cache, e := readRecordsFile(trs.FilePath)
+	if e != nil {
+		return e
+	}
+	trs.TxRecord, trs.nextBlock = cache.Records, cache.NextBlock
+	return nil
+}
+
+// 读取并解析缓存文件
+func readRecordsFile(path string) (cache transactionRecordsFile, e error) {
 	// 读文件
-	file, e := ioutil.ReadFile(trs.FilePath)
+	file, e := ioutil.ReadFile(path)
 	if e != nil {
+		return cache, e
+	}
+
+	// 反序列化JSON，兼容旧版本的数组格式
+	if e = json.Unmarshal(file, &cache); e != nil {
+		e = json.Unmarshal(file, &cache.Records)
+	}
+	if e != nil {
+		return cache, fmt.Errorf("%s: %w: %s", path, ErrCorrupt, e)
+	}
+	return cache, nil
+}
+
+// 加载缓存文件，文件不存在时从区块0同步
+// 文件损坏时将其移至<文件名>.corrupt，从最新的可用备份恢复，没有可用备份时从区块0同步
+func (trs *TransactionRecords) Open(path string) error {
+	trs.FilePath = path
+	if path == "" {
+		return nil
+	}
+	e := trs.LoadTransactionRecords()
+	if e == nil || os.IsNotExist(e) {
+		return nil
+	} else if !errors.Is(e, ErrCorrupt) {
 		return e
 	}
 
-	// 反序列化JSON
-	_ = json.Unmarshal(file, &trs.TxRecord)
+	corrupt := path + ".corrupt"
+	if err := os.Rename(path, corrupt); err != nil {
+		return err
+	}
+	fmt.Printf("交易记录文件损坏，已移至%s：%s\n", corrupt, e)
+
+	trs.mutex.Lock()
+	defer trs.mutex.Unlock()
+	for n := 1; n <= Backups; n++ {
+		cache, err := readRecordsFile(backupPath(path, n))
+		if err != nil {
+			continue
+		}
+		trs.TxRecord, trs.nextBlock = cache.Records, cache.NextBlock
+		fmt.Printf("已从备份%s恢复交易记录，从区块%d继续同步\n", backupPath(path, n), trs.nextBlock)
+		return trs.save()
+	}
+	fmt.Println("没有可用的交易记录备份，从区块0重新同步")
 	return nil
 }
@@ -0,0 +1,185 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"gdzce.cn/perishable-food/application/fbeecloud"
+	"gdzce.cn/perishable-food/application/util"
+)
+
+// 订单已不在运送中，温度更新器应当停止
+var ErrOrderClosed = errors.New("order is not processing")
+
+// 上传温度读数，一般为调用链码的updateOrderTemperature，operatorId为上传温度的物流商账户
+type SubmitFunc func(orderId, operatorId string, reading *fbeecloud.Reading) error
+
+// 单个订单的温度更新器，定时从传感器读取温度并上链
+type FbeeTemperatureUpdater struct {
+	OrderId    string // 订单ID
+	SensorId   string // 绑定的传感器ID
+	OperatorId string // 上传温度的物流商账户ID
+	Interval   int    // 获取间隔（毫秒）
+
+	clear    chan bool // 停止定时器的通道，关闭时定时器停止
+	failures int       // 连续失败次数
+}
+
+// 温度更新器管理，按订单ID记录正在运行的更新器，可并发使用
+type Manager struct {
+	Gateway  fbeecloud.Gateway // 温度传感器网关
+	Submit   SubmitFunc        // 温度上链
+	Interval int               // 获取间隔（毫秒）
+
+	mutex    sync.Mutex
+	updaters map[string]*FbeeTemperatureUpdater
+}
+
+// 创建温度更新器管理
+func NewManager(gateway fbeecloud.Gateway, submit SubmitFunc, interval int) *Manager {
+	return &Manager{
+		Gateway:  gateway,
+		Submit:   submit,
+		Interval: interval,
+		updaters: make(map[string]*FbeeTemperatureUpdater),
+	}
+}
+
+// 为订单启动温度更新器，sensorId为空时使用网关中第一个在线的传感器
+// 同一订单重复启动时不会创建新的更新器
+func (m *Manager) Start(orderId, sensorId, operatorId string) error {
+	if orderId == "" {
+		return errors.New("order id is required")
+	}
+	if operatorId == "" {
+		return errors.New("operator id is required")
+	}
+	if m.Gateway == nil {
+		return errors.New("fbeecloud gateway is not initialized")
+	}
+
+	if sensorId == "" {
+		val, err := m.defaultSensor()
+		if err != nil {
+			return err
+		}
+		sensorId = val
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.updaters[orderId]; ok {
+		return nil
+	}
+
+	updater := &FbeeTemperatureUpdater{
+		OrderId:    orderId,
+		SensorId:   sensorId,
+		OperatorId: operatorId,
+		Interval:   m.Interval,
+	}
+	updater.clear = util.SetInterval(func() { m.poll(updater) }, updater.Interval, false)
+	m.updaters[orderId] = updater
+	fmt.Printf("订单%s温度更新器启动，传感器：%s\n", orderId, sensorId)
+	return nil
+}
+
+// 停止订单的温度更新器，返回是否存在该更新器
+// 不等待正在进行的获取和上传，其结束后定时器不再运行
+func (m *Manager) Stop(orderId string) bool {
+	m.mutex.Lock()
+	updater, ok := m.updaters[orderId]
+	delete(m.updaters, orderId)
+	m.mutex.Unlock()
+
+	if !ok {
+		return false
+	}
+	// 关闭通道不会阻塞，定时器在当前的获取和上传结束后退出
+	close(updater.clear)
+	fmt.Printf("订单%s温度更新器停止\n", orderId)
+	return true
+}
+
+// 停止所有温度更新器
+func (m *Manager) StopAll() {
+	for _, orderId := range m.Running() {
+		m.Stop(orderId)
+	}
+}
+
+// 正在运行温度更新器的订单ID
+func (m *Manager) Running() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	orderIds := make([]string, 0, len(m.updaters))
+	for orderId := range m.updaters {
+		orderIds = append(orderIds, orderId)
+	}
+	return orderIds
+}
+
+// 返回订单绑定的传感器ID
+func (m *Manager) SensorId(orderId string) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	updater, ok := m.updaters[orderId]
+	if !ok {
+		return "", false
+	}
+	return updater.SensorId, true
+}
+
+// 获取一次温度并上链，panic与错误都只记录日志，保证定时器继续运行
+func (m *Manager) poll(updater *FbeeTemperatureUpdater) {
+	defer func() {
+		if err := recover(); err != nil {
+			updater.failures++
+			fmt.Printf("订单%s温度更新异常（连续%d次）：%v\n", updater.OrderId, updater.failures, err)
+		}
+	}()
+
+	// 已停止的更新器可能在定时器退出前再触发一次，此时不再上传
+	if !m.running(updater) {
+		return
+	}
+	reading, err := m.Gateway.Temperature(updater.SensorId)
+	if err == nil && m.running(updater) {
+		err = m.Submit(updater.OrderId, updater.OperatorId, reading)
+	}
+
+	switch {
+	case err == nil:
+		updater.failures = 0
+	case errors.Is(err, ErrOrderClosed):
+		m.Stop(updater.OrderId)
+	default:
+		updater.failures++
+		fmt.Printf("订单%s温度更新失败（连续%d次）：%s\n", updater.OrderId, updater.failures, err)
+	}
+}
+
+// 更新器是否仍在运行，同一订单停止后重新启动的是另一个更新器
+func (m *Manager) running(updater *FbeeTemperatureUpdater) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.updaters[updater.OrderId] == updater
+}
+
+// 网关中第一个在线的传感器
+func (m *Manager) defaultSensor() (string, error) {
+	sensors, err := m.Gateway.SensorList()
+	if err != nil {
+		return "", err
+	}
+	for _, sensor := range sensors {
+		if sensor.Online {
+			return sensor.Id, nil
+		}
+	}
+	return "", errors.New("no online sensor")
+}
@@ -0,0 +1,189 @@
+package updater
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gdzce.cn/perishable-food/application/fbeecloud"
+)
+
+// 假网关，直接返回固定温度
+type fakeGateway struct {
+	sensors []fbeecloud.Sensor
+}
+
+func (g *fakeGateway) Login() error { return nil }
+
+func (g *fakeGateway) SensorList() ([]fbeecloud.Sensor, error) { return g.sensors, nil }
+
+func (g *fakeGateway) Temperature(sensorId string) (*fbeecloud.Reading, error) {
+	if sensorId == "broken" {
+		panic("sensor broken")
+	}
+	return &fbeecloud.Reading{SensorId: sensorId, Temperature: -1, RecordTime: time.Now()}, nil
+}
+
+// 记录上链的温度读数
+type recorder struct {
+	mutex    sync.Mutex
+	readings map[string][]*fbeecloud.Reading
+	closed   map[string]bool
+}
+
+func (r *recorder) submit(orderId, operatorId string, reading *fbeecloud.Reading) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed[orderId] {
+		return ErrOrderClosed
+	}
+	r.readings[orderId] = append(r.readings[orderId], reading)
+	return nil
+}
+
+func (r *recorder) count(orderId string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.readings[orderId])
+}
+
+func newTestManager() (*Manager, *recorder) {
+	r := &recorder{readings: make(map[string][]*fbeecloud.Reading), closed: make(map[string]bool)}
+	gateway := &fakeGateway{sensors: []fbeecloud.Sensor{{Id: "s0"}, {Id: "s1", Online: true}}}
+	return NewManager(gateway, r.submit, 5), r
+}
+
+// 等待条件成立，超时返回false
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+// 启动后定时上传温度，停止后不再上传
+func TestStartStop(t *testing.T) {
+	m, r := newTestManager()
+	if err := m.Start("1", "", "2"); err != nil {
+		t.Fatal(err)
+	}
+	// 重复启动不会创建新的更新器
+	if err := m.Start("1", "s2", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if sensorId, _ := m.SensorId("1"); sensorId != "s1" {
+		t.Fatalf("expected default online sensor s1, got %s", sensorId)
+	}
+	if !waitFor(func() bool { return r.count("1") >= 2 }) {
+		t.Fatal("no temperature submitted")
+	}
+
+	if !m.Stop("1") || m.Stop("1") {
+		t.Fatal("expected stop to succeed exactly once")
+	}
+	// Stop不等待正在进行的上传，等其结束后再计数
+	time.Sleep(10 * time.Millisecond)
+	count := r.count("1")
+	time.Sleep(30 * time.Millisecond)
+	if r.count("1") != count || len(m.Running()) != 0 {
+		t.Fatal("updater still running after stop")
+	}
+}
+
+// 订单不再运送中时更新器自动停止，panic不会终止其他更新器
+func TestSupervision(t *testing.T) {
+	m, r := newTestManager()
+	_ = m.Start("broken", "broken", "2")
+	_ = m.Start("closed", "s1", "2")
+	r.mutex.Lock()
+	r.closed["closed"] = true
+	r.mutex.Unlock()
+
+	if !waitFor(func() bool { _, ok := m.SensorId("closed"); return !ok }) {
+		t.Fatal("closed order updater not stopped")
+	}
+	if _, ok := m.SensorId("broken"); !ok {
+		t.Fatal("panicking updater should keep running")
+	}
+	m.StopAll()
+	if len(m.Running()) != 0 {
+		t.Fatal("expected all updaters stopped")
+	}
+}
+
+// 并发启动和停止
+func TestConcurrentStartStop(t *testing.T) {
+	m, _ := newTestManager()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		orderId := strconv.Itoa(i % 5)
+		go func() {
+			defer wg.Done()
+			_ = m.Start(orderId, "s1", "2")
+		}()
+		go func() {
+			defer wg.Done()
+			m.Stop(orderId)
+		}()
+	}
+	wg.Wait()
+	m.StopAll()
+	if len(m.Running()) != 0 {
+		t.Fatal("expected all updaters stopped")
+	}
+}
+
+// 网关没有在线传感器或缺少物流商账户时启动失败
+func TestStartWithoutSensor(t *testing.T) {
+	m := NewManager(&fakeGateway{}, func(string, string, *fbeecloud.Reading) error { return errors.New("unexpected") }, 5)
+	if err := m.Start("1", "", "2"); err == nil {
+		t.Fatal("expected error without online sensor")
+	}
+	if err := m.Start("1", "s1", ""); err == nil {
+		t.Fatal("expected error without operator")
+	}
+	if err := NewManager(nil, nil, 5).Start("1", "s1", "2"); err == nil {
+		t.Fatal("expected error without gateway")
+	}
+}
+
+// 上传阻塞时停止不被阻塞，上传结束后不再上传
+func TestStopDuringSubmit(t *testing.T) {
+	entered, release := make(chan bool, 1), make(chan bool)
+	submits := 0
+	m := NewManager(&fakeGateway{}, func(string, string, *fbeecloud.Reading) error {
+		submits++
+		entered <- true
+		<-release
+		return nil
+	}, 5)
+	if err := m.Start("1", "s1", "2"); err != nil {
+		t.Fatal(err)
+	}
+	<-entered
+
+	stopped := make(chan bool)
+	go func() {
+		m.Stop("1")
+		m.StopAll()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop blocked by in-flight submit")
+	}
+
+	close(release)
+	time.Sleep(30 * time.Millisecond)
+	if submits != 1 {
+		t.Fatalf("expected 1 submit, got %d", submits)
+	}
+}
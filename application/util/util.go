@@ -6,7 +6,7 @@ import (
 
 // 使用说明：
 // someFunc：运行的函数，milliseconds：间隔时间毫秒值，async：是否异步运行
-// 使用一个变量来接收返回的chan，当往chan发true时，定时器会停止
+// 使用一个变量来接收返回的chan，当往chan发true或关闭chan时，定时器会停止
 func SetInterval(someFunc func(), milliseconds int, async bool) chan bool {
 	// 间隔
 	interval := time.Duration(milliseconds) * time.Millisecond
@@ -26,7 +26,7 @@ func SetInterval(someFunc func(), milliseconds int, async bool) chan bool {
 				} else {
 					someFunc()
 				}
-			case <-clear: // 有信号时，停止定时器
+			case <-clear: // 有信号或通道关闭时，停止定时器
 				ticker.Stop()
 				return
 			}
@@ -0,0 +1,273 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	mspclient "github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/cryptosuite"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+
+	bc "gdzce.cn/perishable-food/application/blockchain"
+)
+
+// 证书中的账户属性，与链码保持一致
+const (
+	AccountIdAttribute   = "account.id"
+	AccountRoleAttribute = "account.role"
+)
+
+// CA相关配置
+var (
+	Affiliation = "org1" // 登记用户所属的部门
+)
+
+var (
+	ErrRevoked = errors.New("identity has been revoked")
+	// fabric-ca存放证书属性的扩展
+	attrsOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+)
+
+// 组织的Fabric CA，负责用户的注册、登记、重新登记和吊销，登记得到的证书和私钥保存在钱包中
+type CA struct {
+	Org      string            // 组织名称
+	Client   *mspclient.Client // sdk的msp客户端
+	Wallet   *Wallet           // 保存身份的钱包
+	KeyStore string            // sdk保存私钥的目录，私钥登记后移入钱包
+
+	mutex sync.Mutex
+}
+
+// 使用sdk创建组织的CA客户端
+func NewCA(sdk *fabsdk.FabricSDK, org string, wallet *Wallet) (*CA, error) {
+	client, err := mspclient.New(sdk.Context(), mspclient.WithOrg(org))
+	if err != nil {
+		return nil, err
+	}
+	backend, err := sdk.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CA{
+		Org:      org,
+		Client:   client,
+		Wallet:   wallet,
+		KeyStore: cryptosuite.ConfigFromBackend(backend).KeyStorePath(),
+	}, nil
+}
+
+// 在CA中注册用户，证书中带上账户ID和角色属性，返回登记口令
+func (ca *CA) Register(user, secret, accountId, role string) (string, error) {
+	if !userNamePattern.MatchString(user) {
+		return "", ErrInvalidUser
+	}
+
+	return ca.Client.Register(&mspclient.RegistrationRequest{
+		Name:        user,
+		Type:        "client",
+		Affiliation: Affiliation,
+		Secret:      secret,
+		Attributes: []mspclient.Attribute{
+			{Name: AccountIdAttribute, Value: accountId, ECert: true},
+			{Name: AccountRoleAttribute, Value: role, ECert: true},
+		},
+	})
+}
+
+// 使用登记口令登记用户，并把证书和私钥保存到钱包
+func (ca *CA) Enroll(user, secret string) (*Entry, error) {
+	if !userNamePattern.MatchString(user) {
+		return nil, ErrInvalidUser
+	}
+
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	if err := ca.Client.Enroll(user, mspclient.WithSecret(secret)); err != nil {
+		return nil, err
+	}
+	return ca.store(user)
+}
+
+// 重新登记用户，私钥不变，换发新证书
+func (ca *CA) Reenroll(user string) (*Entry, error) {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	entry, err := ca.Wallet.Get(user)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Revoked {
+		return nil, ErrRevoked
+	}
+
+	// sdk重新登记时从自己的目录读取私钥，这里临时放回去
+	keyFile, err := ca.keyFile(entry.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(keyFile, entry.Key, 0600); err != nil {
+		return nil, err
+	}
+	defer os.Remove(keyFile)
+
+	if err := ca.Client.Reenroll(user); err != nil {
+		return nil, err
+	}
+	return ca.store(user)
+}
+
+// 吊销用户的所有证书，钱包中的身份标记为已吊销
+func (ca *CA) Revoke(user, reason string) error {
+	if !userNamePattern.MatchString(user) {
+		return ErrInvalidUser
+	}
+
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	if _, err := ca.Client.Revoke(&mspclient.RevocationRequest{Name: user, Reason: reason}); err != nil {
+		return err
+	}
+
+	entry, err := ca.Wallet.Get(user)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entry.Revoked = true
+	return ca.Wallet.Put(entry)
+}
+
+// 用钱包中的身份生成调用链码的身份
+func (ca *CA) Identity(user string) (*bc.Identity, error) {
+	entry, err := ca.Wallet.Get(user)
+	if err != nil {
+		return nil, err
+	}
+	return ca.identity(entry)
+}
+
+// 查找链上账户对应的身份
+func (ca *CA) AccountIdentity(accountId string) (*bc.Identity, error) {
+	entry, err := ca.Wallet.FindByAccount(accountId)
+	if err != nil {
+		return nil, err
+	}
+	return ca.identity(entry)
+}
+
+func (ca *CA) identity(entry *Entry) (*bc.Identity, error) {
+	if entry.Revoked {
+		return nil, ErrRevoked
+	}
+	signer, err := ca.Client.CreateSigningIdentity(msp.WithCert(entry.Cert), msp.WithPrivateKey(entry.Key))
+	if err != nil {
+		return nil, err
+	}
+	return &bc.Identity{Org: entry.Org, User: entry.User, Signer: signer}, nil
+}
+
+// 把sdk登记得到的证书和私钥保存到钱包，并删除sdk目录中的明文私钥
+func (ca *CA) store(user string) (*Entry, error) {
+	signer, err := ca.Client.GetSigningIdentity(user)
+	if err != nil {
+		return nil, err
+	}
+	cert := signer.EnrollmentCertificate()
+	keyFile, err := ca.keyFile(cert)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read private key of %s error: %s", user, err)
+	}
+	attrs, err := certAttributes(cert)
+	if err != nil {
+		return nil, err
+	}
+	// 重新登记时保留登录口令
+	var passwordHash []byte
+	if old, err := ca.Wallet.Get(user); err == nil {
+		passwordHash = old.PasswordHash
+	}
+
+	entry := &Entry{
+		User:      user,
+		Org:       ca.Org,
+		MspId:     signer.Identifier().MSPID,
+		AccountId: attrs[AccountIdAttribute],
+		Role:      attrs[AccountRoleAttribute],
+		Cert:      cert,
+		Key:       key,
+
+		PasswordHash: passwordHash,
+	}
+	if err := ca.Wallet.Put(entry); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(keyFile); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// sdk保存私钥的文件，文件名为公钥的SKI
+func (ca *CA) keyFile(cert []byte) (string, error) {
+	parsed, err := parseCert(cert)
+	if err != nil {
+		return "", err
+	}
+	pub, ok := parsed.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return "", errors.New("certificate public key is not ECDSA")
+	}
+	ski := sha256.Sum256(elliptic.Marshal(pub.Curve, pub.X, pub.Y))
+	return filepath.Join(ca.KeyStore, hex.EncodeToString(ski[:])+"_sk"), nil
+}
+
+// 读取证书中fabric-ca写入的属性
+func certAttributes(cert []byte) (map[string]string, error) {
+	parsed, err := parseCert(cert)
+	if err != nil {
+		return nil, err
+	}
+	for _, ext := range parsed.Extensions {
+		if ext.Id.Equal(attrsOID) {
+			attrs := struct {
+				Attrs map[string]string `json:"attrs"`
+			}{}
+			if err := json.Unmarshal(ext.Value, &attrs); err != nil {
+				return nil, fmt.Errorf("unmarshal certificate attributes error: %s", err)
+			}
+			return attrs.Attrs, nil
+		}
+	}
+	return map[string]string{}, nil
+}
+
+func parseCert(cert []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(cert)
+	if block == nil {
+		return nil, errors.New("invalid certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,231 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 钱包相关配置
+var (
+	Dir           = "./data/wallet"     // 钱包目录
+	PassphraseEnv = "WALLET_PASSPHRASE" // 保存钱包口令的环境变量
+)
+
+const (
+	saltFileName = "salt" // 派生密钥用的盐
+	entrySuffix  = ".id"  // 身份文件后缀
+)
+
+var (
+	ErrNotFound      = errors.New("identity not found in wallet")
+	ErrInvalidUser   = errors.New("invalid user name")
+	ErrDecryptFailed = errors.New("wrong wallet passphrase or corrupt identity")
+	userNamePattern  = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+)
+
+// 钱包中保存的用户身份
+type Entry struct {
+	User      string `json:"user"`      // 用户名，即CA中的登记ID
+	Org       string `json:"org"`       // 组织名称
+	MspId     string `json:"mspId"`     // 组织的MSP ID
+	AccountId string `json:"accountId"` // 对应的链上账户ID
+	Role      string `json:"role"`      // 账户角色
+	Cert      []byte `json:"cert"`      // PEM格式的证书
+	Key       []byte `json:"key"`       // PEM格式的私钥
+	Revoked   bool   `json:"revoked"`   // 证书是否已吊销
+
+	PasswordHash []byte `json:"passwordHash,omitempty"` // 登录口令的bcrypt哈希
+}
+
+// 基于文件的加密钱包，每个用户一个文件，用口令派生的密钥以AES-GCM加密，可并发使用
+type Wallet struct {
+	Dir string
+
+	mutex sync.RWMutex
+	aead  cipher.AEAD
+}
+
+// 打开钱包目录，不存在时创建
+func New(dir, passphrase string) (*Wallet, error) {
+	if passphrase == "" {
+		return nil, errors.New("wallet passphrase is required")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	salt, err := loadSalt(filepath.Join(dir, saltFileName))
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Wallet{Dir: dir, aead: aead}, nil
+}
+
+// 使用默认目录和环境变量中的口令打开钱包
+func InitWallet() (*Wallet, error) {
+	return New(Dir, os.Getenv(PassphraseEnv))
+}
+
+// 保存身份，已存在时覆盖
+func (w *Wallet) Put(entry *Entry) error {
+	if !userNamePattern.MatchString(entry.User) {
+		return ErrInvalidUser
+	}
+
+	plain, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, w.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	// 以用户名作为附加数据，防止身份文件被互相替换
+	sealed := w.aead.Seal(nonce, nonce, plain, []byte(entry.User))
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// 先写临时文件再重命名，避免写入中断时损坏原有身份
+	path := w.path(entry.User)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, sealed, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// 读取身份
+func (w *Wallet) Get(user string) (*Entry, error) {
+	if !userNamePattern.MatchString(user) {
+		return nil, ErrInvalidUser
+	}
+
+	w.mutex.RLock()
+	sealed, err := ioutil.ReadFile(w.path(user))
+	w.mutex.RUnlock()
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	size := w.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, ErrDecryptFailed
+	}
+	plain, err := w.aead.Open(nil, sealed[:size], sealed[size:], []byte(user))
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	entry := new(Entry)
+	if err := json.Unmarshal(plain, entry); err != nil {
+		return nil, fmt.Errorf("unmarshal identity %s error: %s", user, err)
+	}
+	return entry, nil
+}
+
+// 删除身份
+func (w *Wallet) Remove(user string) error {
+	if !userNamePattern.MatchString(user) {
+		return ErrInvalidUser
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := os.Remove(w.path(user))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// 钱包中所有的用户名，按字母排序
+func (w *Wallet) List() ([]string, error) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	files, err := ioutil.ReadDir(w.Dir)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]string, 0)
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), entrySuffix) {
+			users = append(users, strings.TrimSuffix(file.Name(), entrySuffix))
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// 查找链上账户对应的未吊销身份
+func (w *Wallet) FindByAccount(accountId string) (*Entry, error) {
+	users, err := w.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		entry, err := w.Get(user)
+		if err != nil {
+			return nil, err
+		}
+		if entry.AccountId == accountId && !entry.Revoked {
+			return entry, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (w *Wallet) path(user string) string {
+	return filepath.Join(w.Dir, user+entrySuffix)
+}
+
+// 读取盐，不存在时生成
+func loadSalt(path string) ([]byte, error) {
+	salt, err := ioutil.ReadFile(path)
+	if err == nil {
+		return salt, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	salt = make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, salt, 0600); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
@@ -0,0 +1,21 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"gdzce.cn/perishable-food/chaincode/perishable-food/perishablefood"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+func main() {
+	err := shim.Start(new(perishablefood.PerishableFood))
+	if err != nil {
+		fmt.Printf("Error starting Simple chaincode: %s", err)
+	}
+}
@@ -0,0 +1,1573 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package perishablefood
+
+import (
+	"encoding/json"
+	"fmt"
+	_ "golang.org/x/crypto/bcrypt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// PerishableFood 生鲜食品供应链链码，由链码入口启动，也可以在应用程序中以MockStub运行
+type PerishableFood struct {
+}
+
+// 账户
+type Account struct {
+	Id          string  `json:"id"`          // 账号ID
+	Name        string  `json:"name"`        // 账号名
+	Balance     float64 `json:"balance"`     // 余额
+	Role        string  `json:"role"`        // 角色
+	MspId       string  `json:"mspId"`       // 账户所属组织的MSP ID，为空时不限制组织
+	Deactivated bool    `json:"deactivated"` // 是否已注销
+}
+
+// 调用者证书中记录账户ID和角色的属性名，由Fabric CA登记用户时写入
+const (
+	accountIdAttribute   = "account.id"
+	accountRoleAttribute = "account.role"
+)
+
+// 管理员，组织管理员证书的OU或账户角色属性为admin，不是账户的角色
+const roleAdmin = "admin"
+
+// 订单不在运送中时记录温度返回的状态码，应用程序据此停止温度更新器，不依赖错误信息的文字
+const StatusOrderClosed = 409
+
+// 账户角色
+const (
+	roleSupplier  = "supplier"  // 供货商
+	roleCarrier   = "carrier"   // 物流商
+	roleBuyer     = "buyer"     // 买家
+	roleRegulator = "regulator" // 监管方，只能查询
+)
+
+var roleList = []string{roleSupplier, roleCarrier, roleBuyer, roleRegulator}
+
+// 旧版本的账户没有角色，按初始化时的账户名推断
+var legacyRoleMap = map[string]string{
+	"供货商": roleSupplier,
+	"物流商": roleCarrier,
+	"买家":  roleBuyer,
+}
+
+// 车位
+type Commodity struct {
+	Name            string  `json:"name"`            // 商品名
+	Id              string  `json:"id"`              // 商品ID
+	Location        string  `json:"location"`        // 地方
+	LowTemperature  float64 `json:"lowTemperature"`  // 约定最低温
+	HighTemperature float64 `json:"highTemperature"` // 约定最高温
+	Price           float64 `json:"price"`           // 费率
+	OwnerId         string  `json:"owner"`           // 所有者
+}
+
+// 温度
+type Temperature struct {
+	Temperature float64   `json:"temperature"` // 温度（摄氏度）
+	RecordTime  time.Time `json:"record_time"` // 记录时间
+	SensorId    string    `json:"sensor_id"`   // 传感器ID
+}
+
+// 结算明细
+type Settlement struct {
+	TotalPrice    float64 `json:"totalPrice"`    // 应付总额
+	LowDeviation  float64 `json:"lowDeviation"`  // 最低温度偏差值
+	HighDeviation float64 `json:"highDeviation"` // 最高温度偏差值
+	Deduction     float64 `json:"deduction"`     // 温度超出约定范围的扣款
+	Payment       float64 `json:"payment"`       // 买家实付金额
+	Freight       float64 `json:"freight"`       // 物流商收入
+	SellerIncome  float64 `json:"sellerIncome"`  // 供货商收入
+}
+
+// 物流费计算方式
+const (
+	freightTypeRate  = "rate"  // 按总额的百分比
+	freightTypeFixed = "fixed" // 固定运费
+)
+
+// 订单
+type Order struct {
+	Commodity            *Commodity     `json:"commodity"`            //商品
+	DeliverAddress       string         `json:"deliverAddress"`       //配送地址
+	Id                   string         `json:"id"`                   //订单ID
+	OrderTime            time.Time      `json:"orderTime"`            //下单时间
+	DeliverTime          time.Time      `json:"deliverTime"`          //预计送达时间
+	Quantity             int64          `json:"quantity"`             //数量
+	Status               string         `json:"status"`               //订单状态
+	PreviousStatus       string         `json:"previousStatus"`       //上一个订单状态
+	StatusTime           time.Time      `json:"statusTime"`           //状态变更时间
+	SensorId             string         `json:"sensorId"`             //温度传感器ID
+	TemperatureVariation []*Temperature `json:"temperatureVariation"` //温度变化
+	BuyerId              string         `json:"buyer"`                //买家
+	SellerId             string         `json:"seller"`               //卖家
+	CarrierId            string         `json:"carrier"`              //物流商
+	FreightType          string         `json:"freightType"`          //物流费计算方式
+	Freight              float64        `json:"freight"`              //物流费百分比或固定运费
+	Settlement           *Settlement    `json:"settlement"`           //结算明细
+}
+
+// 链码事件类型，Fabric每个交易只保留最后一个事件，每个改变状态的函数成功时设置一个
+const (
+	EventCommodityCreated    = "CommodityCreated"    // 创建商品
+	EventOrderCreated        = "OrderCreated"        // 新建订单
+	EventOrderStatusChanged  = "OrderStatusChanged"  // 订单状态变更（完成除外）
+	EventSettlementCompleted = "SettlementCompleted" // 订单完成并结算
+	EventTemperatureRecorded = "TemperatureRecorded" // 记录订单温度
+	EventAccountCreated      = "AccountCreated"      // 创建账户
+	EventAccountUpdated      = "AccountUpdated"      // 更新账户名称
+	EventAccountDeactivated  = "AccountDeactivated"  // 注销账户
+	EventOrderStatusMigrated = "OrderStatusMigrated" // 迁移旧版本订单状态
+)
+
+// 链码事件，事件名即Type，与事件无关的字段为空
+type Event struct {
+	Type           string       `json:"type"`                     // 事件类型
+	TxId           string       `json:"txId"`                     // 交易ID
+	Time           time.Time    `json:"time"`                     // 交易时间
+	OrderId        string       `json:"orderId,omitempty"`        // 订单ID
+	CommodityId    string       `json:"commodityId,omitempty"`    // 商品ID
+	AccountId      string       `json:"accountId,omitempty"`      // 账户ID
+	OperatorId     string       `json:"operatorId,omitempty"`     // 操作人
+	BuyerId        string       `json:"buyer,omitempty"`          // 买家
+	SellerId       string       `json:"seller,omitempty"`         // 卖家
+	CarrierId      string       `json:"carrier,omitempty"`        // 物流商
+	Status         string       `json:"status,omitempty"`         // 订单状态
+	PreviousStatus string       `json:"previousStatus,omitempty"` // 上一个订单状态
+	Quantity       int64        `json:"quantity,omitempty"`       // 订单数量
+	Amount         float64      `json:"amount,omitempty"`         // 金额：订单总额、商品单价或账户余额
+	Temperature    *Temperature `json:"temperature,omitempty"`    // 记录的温度
+	Settlement     *Settlement  `json:"settlement,omitempty"`     // 结算明细
+	Count          int          `json:"count,omitempty"`          // 迁移的订单数量
+}
+
+// 订单状态
+type Status struct {
+	New        string // 新建
+	Processing string // 运送中
+	Done       string // 完成
+	Canceled   string // 取消
+}
+
+// 状态枚举，账本中存储与语言无关的状态码，显示名称由应用层转换
+func newStatus() *Status {
+	return &Status{
+		New:        "New",
+		Processing: "Processing",
+		Done:       "Done",
+		Canceled:   "Canceled",
+	}
+}
+
+var enumStatus = newStatus()
+
+// 所有的订单状态
+var statusList = []string{enumStatus.New, enumStatus.Processing, enumStatus.Done, enumStatus.Canceled}
+
+// 旧版本账本中存储的中文状态与状态码的对应关系，用于数据迁移
+var legacyStatusMap = map[string]string{
+	"新建":  enumStatus.New,
+	"运送中": enumStatus.Processing,
+	"完成":  enumStatus.Done,
+	"取消":  enumStatus.Canceled,
+}
+
+// 判断是否为合法的订单状态
+func isValidStatus(status string) bool {
+	for _, val := range statusList {
+		if val == status {
+			return true
+		}
+	}
+	return false
+}
+
+// 订单状态的合法转换：新建→运送中→完成，新建、运送中的订单可以取消
+var statusTransitions = map[string][]string{
+	enumStatus.New:        {enumStatus.Processing, enumStatus.Canceled},
+	enumStatus.Processing: {enumStatus.Done, enumStatus.Canceled},
+}
+
+// 判断订单能否从from状态转换到to状态
+func canTransition(from, to string) bool {
+	for _, val := range statusTransitions[from] {
+		if val == to {
+			return true
+		}
+	}
+	return false
+}
+
+// 链码初始化
+func (t *PerishableFood) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	fmt.Println("链码初始化")
+
+	// 初始化默认数据
+	var names = [3]string{"国光", "红星", "红富士"}
+	var ids = [3]string{
+		"88efd7ea-bec6-4994-8ed1-f3f7b6f8cac7",
+		"36bf5c7f-4cf7-4926-b0f6-0c5c18515752",
+		"d9ce807b-e308-11e8-a47c-3e1591a6f5bb"}
+	var accountsName = [4]string{"供货商", "物流商", "买家", "监管方"}
+	var accountsRole = [4]string{roleSupplier, roleCarrier, roleBuyer, roleRegulator}
+	var accountList []string
+
+	// 初始账户归属于初始化链码的组织，其他组织的同名账户属性无效
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get msp id error %s", err))
+	}
+
+	// 初始化账号数据，为“供应商”，“物流商”，“买家”，“监管方”账号初始化账号，监管方不参与结算
+	for i, val := range accountsName {
+		account := &Account{
+			Name:  val,
+			Id:    strconv.Itoa(i + 1),
+			Role:  accountsRole[i],
+			MspId: mspId,
+		}
+		if account.Role != roleRegulator {
+			account.Balance = 1000
+		}
+		// 序列化对象
+		bytes, err := json.Marshal(account)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("marshal account error %s", err))
+		}
+
+		var key string
+		if val, err := stub.CreateCompositeKey("account", []string{account.Id}); err != nil {
+			return shim.Error(fmt.Sprintf("create key error %s", err))
+		} else {
+			key = val
+		}
+
+		if err := stub.PutState(key, bytes); err != nil {
+			return shim.Error(fmt.Sprintf("put account error %s", err))
+		}
+		accountList = append(accountList, account.Id)
+	}
+
+	// 初始化商品数据，"国光", "红星", "红富士" 3种商品
+	for i, val := range names {
+		price := 6.00 + float64(i)
+		commodity := &Commodity{
+			Name:            val,
+			Id:              ids[i],
+			Location:        "中国",
+			LowTemperature:  -2,
+			HighTemperature: 0,
+			Price:           price, //单价
+			OwnerId:         accountList[0],
+		}
+
+		// 序列化对象
+		commodityBytes, err := json.Marshal(commodity)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("marshal commodity error %s", err))
+		}
+
+		var key string
+		if val, err := stub.CreateCompositeKey("commodity", []string{commodity.Id}); err != nil {
+			return shim.Error(fmt.Sprintf("create key error %s", err))
+		} else {
+			key = val
+		}
+
+		if err := stub.PutState(key, commodityBytes); err != nil {
+			return shim.Error(fmt.Sprintf("put commodity error %s", err))
+		}
+	}
+
+	return shim.Success(nil)
+}
+
+// 实现Invoke接口调用智能合约，例子中的所有智能合约都会集中在这个接口实现
+func (t *PerishableFood) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	funcName, args := stub.GetFunctionAndParameters()
+
+	switch funcName {
+	// 创建商品ok/新建车位
+	case "createCommodity":
+		return createCommodity(stub, args)
+	// 创建订单/发起出租
+	case "createOrder":
+		return createOrder(stub, args)
+	// 查询商品列表ok
+	case "queryCommodityList":
+		return queryCommodityList(stub, args)
+	// 查询订单列表
+	case "queryOrderList":
+		return queryOrderList(stub, args)
+	// 查询账户ok
+	case "queryAccount":
+		return queryAccount(stub, args)
+	// 更新订单温度
+	case "updateOrderTemperature":
+		return updateOrderTemperature(stub, args)
+	// 更新订单状态
+	case "updateOrderStatus":
+		return updateOrderStatus(stub, args)
+	// 迁移旧版本订单的中文状态
+	case "migrateOrderStatus":
+		return migrateOrderStatus(stub, args)
+	// 创建账户
+	case "createAccount":
+		return createAccount(stub, args)
+	// 更新账户
+	case "updateAccount":
+		return updateAccount(stub, args)
+	// 注销账户
+	case "deactivateAccount":
+		return deactivateAccount(stub, args)
+	default:
+		return shim.Error(fmt.Sprintf("unsupported function: %s", funcName))
+	}
+}
+
+// 新建商品
+func createCommodity(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数
+	if len(args) != 7 {
+		return shim.Error("not enough args")
+	}
+
+	// 验证参数的正确性
+	name := args[0]
+	id := args[1]
+	location := args[2]
+	lowTemperature := args[3]
+	highTemperature := args[4]
+	price := args[5]
+	ownerId := args[6]
+
+	if name == "" || id == "" || location == "" || lowTemperature == "" || highTemperature == "" || price == "" || ownerId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 创建主键
+	var key string
+	if val, err := stub.CreateCompositeKey("commodity", []string{id}); err != nil {
+		return shim.Error(fmt.Sprintf("create key error %s", err))
+	} else {
+		key = val
+	}
+
+	// 验证数据是否存在 应该存在 or 不应该存在
+	if commodityBytes, err := stub.GetState(key); err == nil && len(commodityBytes) != 0 {
+		return shim.Error("commodity already exist")
+	}
+
+	// 数据格式转换
+	var formattedPrice float64
+	if val, err := strconv.ParseFloat(price, 64); err != nil {
+		return shim.Error("format price error")
+	} else {
+		formattedPrice = val
+	}
+
+	var formattedLowTemperature float64
+	if val, err := strconv.ParseFloat(lowTemperature, 64); err != nil {
+		return shim.Error("format lowTemperature error")
+	} else {
+		formattedLowTemperature = val
+	}
+
+	var formattedHighTemperature float64
+	if val, err := strconv.ParseFloat(highTemperature, 64); err != nil {
+		return shim.Error("format highTemperature error")
+	} else {
+		formattedHighTemperature = val
+	}
+
+	if formattedLowTemperature > formattedHighTemperature {
+		return shim.Error("lowTemperature must not be greater than highTemperature")
+	}
+
+	// 只有供货商本人才能创建商品
+	owner, err := getAccountWithRole(stub, ownerId, roleSupplier)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkCaller(stub, owner); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 写入状态
+	commodity := &Commodity{
+		Name:            name,
+		Id:              id,
+		Location:        location,
+		LowTemperature:  formattedLowTemperature,
+		HighTemperature: formattedHighTemperature,
+		Price:           formattedPrice, // 单价
+		OwnerId:         ownerId,
+	}
+
+	// 序列化对象
+	commodityBytes, err := json.Marshal(commodity)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal commodity error %s", err))
+	}
+
+	// 写入区块链账本
+	if err := stub.PutState(key, commodityBytes); err != nil {
+		return shim.Error(fmt.Sprintf("put commodity error %s", err))
+	}
+
+	if err := setEvent(stub, &Event{
+		Type:        EventCommodityCreated,
+		CommodityId: id,
+		SellerId:    ownerId,
+		Amount:      formattedPrice,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 成功返回
+	return shim.Success(nil)
+}
+
+// 新建订单
+func createOrder(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数
+	if len(args) != 12 {
+		return shim.Error("not enough args")
+	}
+
+	// 验证参数的正确性
+	commodityId := args[0]
+	id := args[1]
+	orderTime := args[2]
+	status := args[3]
+	buyerId := args[4]
+	sellerId := args[5]
+	deliverAddress := args[6]
+	deliverTime := args[7]
+	quantity := args[8]
+	carrierId := args[9]
+	freightType := args[10]
+	freight := args[11]
+
+	if commodityId == "" || id == "" || status == "" || buyerId == "" || sellerId == "" || orderTime == "" || deliverAddress == "" || deliverTime == "" || quantity == "" || carrierId == "" || freightType == "" || freight == "" {
+		return shim.Error("invalid args")
+	}
+
+	commodity := new(Commodity)
+	// 验证数据是否存在 应该存在 or 不应该存在
+	result, err := stub.GetStateByPartialCompositeKey("commodity", []string{commodityId})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Get commodity error %s", err))
+	}
+	defer result.Close()
+	if result.HasNext() {
+		val, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("Get commodity error %s", err))
+		}
+
+		if err := json.Unmarshal(val.GetValue(), commodity); err != nil {
+			return shim.Error(fmt.Sprintf("Commodity failed to convert from bytes, error %s", err))
+		}
+	} else {
+		return shim.Error("Commodity not exists")
+	}
+
+	// 买家、卖家和物流商必须是对应角色的有效账户
+	parties := [][2]string{{buyerId, roleBuyer}, {sellerId, roleSupplier}, {carrierId, roleCarrier}}
+	for i, val := range parties {
+		account, err := getAccountWithRole(stub, val[0], val[1])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		// 订单由买家本人发起
+		if i == 0 {
+			if err := checkCaller(stub, account); err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+	}
+
+	// 数据格式转换
+	var formattedOrderTime time.Time
+	if val, err := parseTime(orderTime); err != nil {
+		return shim.Error(fmt.Sprintf("formate orderTime error: %s", err))
+	} else {
+		formattedOrderTime = val
+	}
+
+	var formattedDeliverTime time.Time
+	if val, err := parseTime(deliverTime); err != nil {
+		return shim.Error(fmt.Sprintf("formate deliverTime error: %s", err))
+	} else {
+		formattedDeliverTime = val
+	}
+
+	// 预计送达时间必须晚于下单时间
+	if !formattedDeliverTime.After(formattedOrderTime) {
+		return shim.Error("deliverTime must be after orderTime")
+	}
+
+	// 数量必须为正整数
+	var formattedQuantity int64
+	if val, err := strconv.ParseInt(quantity, 10, 64); err != nil || val <= 0 {
+		return shim.Error("quantity must be a positive integer")
+	} else {
+		formattedQuantity = val
+	}
+
+	// 物流费为百分比（0-100）或固定运费
+	var formattedFreight float64
+	if val, err := strconv.ParseFloat(freight, 64); err != nil || val < 0 {
+		return shim.Error("freight must be a non-negative number")
+	} else {
+		formattedFreight = val
+	}
+	switch freightType {
+	case freightTypeRate:
+		if formattedFreight > 100 {
+			return shim.Error("freight rate must not be greater than 100")
+		}
+	case freightTypeFixed:
+	default:
+		return shim.Error(fmt.Sprintf("unsupported freightType: %s", freightType))
+	}
+
+	// 写入状态
+	order := &Order{
+		Commodity:      commodity,
+		DeliverAddress: deliverAddress,
+		Id:             id,
+		OrderTime:      formattedOrderTime,
+		DeliverTime:    formattedDeliverTime,
+		Quantity:       formattedQuantity,
+		Status:         enumStatus.New,
+		BuyerId:        buyerId,
+		SellerId:       sellerId,
+		CarrierId:      carrierId,
+		FreightType:    freightType,
+		Freight:        formattedFreight,
+	}
+
+	// 序列化对象
+	orderBytes, err := json.Marshal(order)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal order error %s", err))
+	}
+
+	// 创建主键
+	var key string
+	if val, err := stub.CreateCompositeKey("order", []string{id}); err != nil {
+		return shim.Error(fmt.Sprintf("create key error %s", err))
+	} else {
+		key = val
+	}
+
+	if arr, err := stub.GetState(key); err != nil || len(arr) != 0 {
+		return shim.Error("order already exists")
+	}
+
+	// 写入区块链账本
+	if err := stub.PutState(key, orderBytes); err != nil {
+		return shim.Error(fmt.Sprintf("put order error %s", err))
+	}
+
+	if err := setEvent(stub, &Event{
+		Type:        EventOrderCreated,
+		OrderId:     id,
+		CommodityId: commodityId,
+		BuyerId:     buyerId,
+		SellerId:    sellerId,
+		CarrierId:   carrierId,
+		Status:      order.Status,
+		Quantity:    formattedQuantity,
+		Amount:      roundAmount(float64(formattedQuantity) * commodity.Price),
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 成功返回
+	return shim.Success(nil)
+}
+
+// 查询商品列表
+func queryCommodityList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数
+	if len(args) > 1 {
+		return shim.Error("no args required.")
+	}
+
+	keys := make([]string, 0)
+
+	if len(args) == 1 {
+		commodityId := args[0]
+		if commodityId == "" {
+			return shim.Error("invalid args")
+		}
+		keys = append(keys, commodityId)
+	}
+	// 通过主键从区块链查找相关的数据
+	result, err := stub.GetStateByPartialCompositeKey("commodity", keys)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("query commodity error: %s", err))
+	}
+	defer result.Close()
+
+	// 检查返回的数据是否为空，不为空则遍历数据，否则返回空数组
+	commoditylist := make([]*Commodity, 0)
+	for result.HasNext() {
+		val, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query commodity error: %s", err))
+		}
+
+		commodity := new(Commodity)
+		if err := json.Unmarshal(val.GetValue(), commodity); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+		}
+
+		commoditylist = append(commoditylist, commodity)
+	}
+
+	// 序列化数据
+	bytes, err := json.Marshal(commoditylist)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(bytes)
+}
+
+// 查询订单列表
+func queryOrderList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数，参数为可选的订单ID
+	if len(args) > 1 {
+		return shim.Error("no args required.")
+	}
+
+	keys := make([]string, 0)
+
+	if len(args) == 1 {
+		orderId := args[0]
+		if orderId == "" {
+			return shim.Error("invalid args")
+		}
+		keys = append(keys, orderId)
+	}
+
+	// 管理员和监管方可以查询所有订单，其他账户只能查询自己参与的订单
+	viewer, err := getViewer(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 通过主键从区块链查找相关的数据
+	result, err := stub.GetStateByPartialCompositeKey("order", keys)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("query order error: %s", err))
+	}
+	defer result.Close()
+
+	// 检查返回的数据是否为空，不为空则遍历数据，否则返回空数组
+	orders := make([]*Order, 0)
+	for result.HasNext() {
+		val, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query orders error: %s", err))
+		}
+
+		order := new(Order)
+		if err := json.Unmarshal(val.GetValue(), order); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+		}
+
+		if viewer != nil && !isOrderParticipant(order, viewer.Id) {
+			continue
+		}
+		orders = append(orders, order)
+	}
+
+	// 序列化数据
+	bytes, err := json.Marshal(orders)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(bytes)
+}
+
+// 查询账号
+func queryAccount(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args.")
+	}
+
+	// 验证参数的正确性
+	accountId := args[0]
+
+	if accountId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 管理员和监管方可以查询所有账户，其他账户只能查询自己
+	viewer, err := getViewer(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if viewer != nil {
+		if accountId != "all" && accountId != viewer.Id {
+			return shim.Error(fmt.Sprintf("account %s can not query account %s", viewer.Id, accountId))
+		}
+		accountId = viewer.Id
+	}
+
+	keys := make([]string, 0)
+	if accountId != "all" {
+		keys = append(keys, accountId)
+	}
+
+	// 通过主键从区块链查找相关的数据
+	result, err := stub.GetStateByPartialCompositeKey("account", keys)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("query account error: %s", err))
+	}
+	defer result.Close()
+
+	// 检查返回的数据是否为空，不为空则遍历数据，否则返回空数组
+	accounts := make([]*Account, 0)
+	for result.HasNext() {
+		val, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query accounts error: %s", err))
+		}
+
+		account := new(Account)
+		if err := json.Unmarshal(val.GetValue(), account); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	// 序列化数据
+	bytes, err := json.Marshal(accounts)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal error: %s", err))
+	}
+
+	return shim.Success(bytes)
+}
+
+// 创建账户，管理员可以创建任意账户，其他调用者只能按证书中的账户ID和角色创建自己的账户，且初始余额为0
+func createAccount(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数
+	if len(args) != 4 {
+		return shim.Error("not enough args")
+	}
+
+	// 验证参数的正确性
+	id := args[0]
+	name := args[1]
+	balance := args[2]
+	role := args[3]
+
+	if id == "" || name == "" || balance == "" || role == "" || id == "all" {
+		return shim.Error("invalid args")
+	}
+
+	if !isValidRole(role) {
+		return shim.Error(fmt.Sprintf("unsupported role: %s", role))
+	}
+
+	var formattedBalance float64
+	if val, err := strconv.ParseFloat(balance, 64); err != nil || val < 0 {
+		return shim.Error("balance must be a non-negative number")
+	} else {
+		formattedBalance = val
+	}
+
+	// 创建主键
+	var key string
+	if val, err := stub.CreateCompositeKey("account", []string{id}); err != nil {
+		return shim.Error(fmt.Sprintf("create key error %s", err))
+	} else {
+		key = val
+	}
+
+	// 验证数据是否存在 应该存在 or 不应该存在
+	if accountBytes, err := stub.GetState(key); err != nil || len(accountBytes) != 0 {
+		return shim.Error("account already exists")
+	}
+
+	// 账户归属于创建它的组织
+	mspId, callerId, err := getCaller(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	admin, err := isAdmin(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !admin {
+		callerRole, _, err := cid.GetAttributeValue(stub, accountRoleAttribute)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("get %s attribute error %s", accountRoleAttribute, err))
+		}
+		if callerId != id || callerRole != role {
+			return shim.Error(fmt.Sprintf("caller is not admin or %s account %s", role, id))
+		}
+		if formattedBalance != 0 {
+			return shim.Error("only admin can create an account with balance")
+		}
+	}
+
+	account := &Account{
+		Id:      id,
+		Name:    name,
+		Balance: roundAmount(formattedBalance),
+		Role:    role,
+		MspId:   mspId,
+	}
+	if err := putAccount(stub, key, account); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := setEvent(stub, &Event{Type: EventAccountCreated, AccountId: id, Amount: account.Balance}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 成功返回
+	return shim.Success(nil)
+}
+
+// 更新账户名称，只有管理员或账户本人可以修改，余额只能通过订单结算变更
+func updateAccount(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数
+	if len(args) != 2 {
+		return shim.Error("not enough args")
+	}
+
+	// 验证参数的正确性
+	id := args[0]
+	name := args[1]
+
+	if id == "" || name == "" {
+		return shim.Error("invalid args")
+	}
+
+	account, err := getActiveAccount(stub, id)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := checkAdminOrCaller(stub, account); err != nil {
+		return shim.Error(err.Error())
+	}
+	account.Name = name
+
+	key, err := stub.CreateCompositeKey("account", []string{id})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("create key error %s", err))
+	}
+	if err := putAccount(stub, key, account); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := setEvent(stub, &Event{Type: EventAccountUpdated, AccountId: id}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 成功返回
+	return shim.Success(nil)
+}
+
+// 注销账户，只有管理员或监管方可以注销，注销后不能再创建商品和订单，已有订单仍可正常结算
+func deactivateAccount(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数
+	if len(args) != 1 {
+		return shim.Error("not enough args")
+	}
+
+	// 验证参数的正确性
+	id := args[0]
+
+	if id == "" {
+		return shim.Error("invalid args")
+	}
+
+	if err := checkAdminOrRegulator(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	account, err := getActiveAccount(stub, id)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	account.Deactivated = true
+
+	key, err := stub.CreateCompositeKey("account", []string{id})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("create key error %s", err))
+	}
+	if err := putAccount(stub, key, account); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if err := setEvent(stub, &Event{Type: EventAccountDeactivated, AccountId: id}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 成功返回
+	return shim.Success(nil)
+}
+
+// 更新订单温度，运送中的订单才能记录温度，只有订单的物流商才能上传
+func updateOrderTemperature(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数，第五个参数为可选的传感器ID
+	if len(args) != 4 && len(args) != 5 {
+		return shim.Error("not enough args.")
+	}
+
+	// 验证参数的正确性
+	orderId := args[0]
+	temperature := args[1]
+	recordTime := args[2]
+	operatorId := args[3]
+	var sensorId string
+	if len(args) == 5 {
+		sensorId = args[4]
+	}
+
+	if orderId == "" || temperature == "" || recordTime == "" || operatorId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 数据格式转换
+	var formattedTemperature float64
+	if val, err := strconv.ParseFloat(temperature, 64); err != nil {
+		return shim.Error("format temperature error")
+	} else {
+		formattedTemperature = val
+	}
+
+	var formattedRecordTime time.Time
+	if val, err := parseTime(recordTime); err != nil {
+		return shim.Error(fmt.Sprintf("formate recordTime error: %s", err))
+	} else {
+		formattedRecordTime = val
+	}
+
+	// 查找订单
+	order, key, err := getOrder(stub, orderId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 只有运送中的订单才能记录温度，新建、完成、取消的订单拒绝记录
+	if order.Status != enumStatus.Processing {
+		return pb.Response{Status: StatusOrderClosed, Message: fmt.Sprintf("order is not processing, current status: %s", order.Status)}
+	}
+
+	if err := checkOrderCarrier(stub, order, operatorId); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	record := &Temperature{
+		Temperature: formattedTemperature,
+		RecordTime:  formattedRecordTime,
+		SensorId:    sensorId,
+	}
+	order.TemperatureVariation = append(order.TemperatureVariation, record)
+
+	// 序列化对象
+	orderBytes, err := json.Marshal(order)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal order error %s", err))
+	}
+
+	// 写入区块链账本
+	if err := stub.PutState(key, orderBytes); err != nil {
+		return shim.Error(fmt.Sprintf("put order error %s", err))
+	}
+
+	if err := setEvent(stub, &Event{
+		Type:        EventTemperatureRecorded,
+		OrderId:     orderId,
+		OperatorId:  operatorId,
+		Temperature: record,
+	}); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 更新订单状态，只有订单的物流商才能开始运送，其他状态由订单的参与方变更
+func updateOrderStatus(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数，第四个参数为可选的传感器ID
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error("not enough args.")
+	}
+
+	// 验证参数的正确性
+	orderId := args[0]
+	status := args[1]
+	operatorId := args[2]
+	var sensorId string
+	if len(args) == 4 {
+		sensorId = args[3]
+	}
+
+	if orderId == "" || status == "" || operatorId == "" {
+		return shim.Error("invalid args")
+	}
+
+	// 检查状态是否合法
+	if !isValidStatus(status) {
+		return shim.Error(fmt.Sprintf("unsupported status: %s", status))
+	}
+	newStatus := status
+
+	// 通过主键从区块链查找订单，订单不存在时返回错误
+	order, key, err := getOrder(stub, orderId)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 检查状态转换是否合法
+	if !canTransition(order.Status, newStatus) {
+		return shim.Error(fmt.Sprintf("illegal status transition: %s -> %s", order.Status, newStatus))
+	}
+
+	// 检查操作人的角色
+	if newStatus == enumStatus.Processing {
+		err = checkOrderCarrier(stub, order, operatorId)
+	} else {
+		err = checkOrderParticipant(stub, order, operatorId)
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// 记录状态变更，变更时间使用交易时间
+	statusTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get tx timestamp error %s", err))
+	}
+	order.PreviousStatus = order.Status
+	order.Status = newStatus
+	order.StatusTime = statusTime
+
+	// 开始运送时绑定温度传感器
+	if newStatus == enumStatus.Processing && sensorId != "" {
+		order.SensorId = sensorId
+	}
+
+	// 订单完成状态的处理逻辑
+	/**
+	  若温度超出约定范围，将按如下公式进行计算。
+	  没有超出范围将正常付款。
+	  扣款计算公式：扣款 = 最低温度偏差值 * 0.1 * 货物数量 + 最高温度偏差值 * 0.2 * 货物数量
+	  买家支付的金额按约定分给物流商和供货商，扣款从供货商的收入中减去。
+	*/
+	if newStatus == enumStatus.Done {
+		settlement := calculateSettlement(order)
+		if err := settleOrder(stub, order, settlement); err != nil {
+			return shim.Error(err.Error())
+		}
+		order.Settlement = settlement
+	}
+
+	// 序列化对象
+	orderBytes, err := json.Marshal(order)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("marshal order error %s", err))
+	}
+
+	// 写入区块链账本
+	if err := stub.PutState(key, orderBytes); err != nil {
+		return shim.Error(fmt.Sprintf("put order error %s", err))
+	}
+
+	// 完成时只发出结算事件，其中也带有状态变更
+	event := &Event{
+		Type:           EventOrderStatusChanged,
+		OrderId:        orderId,
+		OperatorId:     operatorId,
+		BuyerId:        order.BuyerId,
+		SellerId:       order.SellerId,
+		CarrierId:      order.CarrierId,
+		Status:         order.Status,
+		PreviousStatus: order.PreviousStatus,
+	}
+	if newStatus == enumStatus.Done {
+		event.Type = EventSettlementCompleted
+		event.Amount = order.Settlement.Payment
+		event.Settlement = order.Settlement
+	}
+	if err := setEvent(stub, event); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// 将旧版本账本中以中文存储的订单状态改写为状态码，返回迁移的订单数量，只有管理员可以调用
+func migrateOrderStatus(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	// 检查参数的个数
+	if len(args) != 0 {
+		return shim.Error("no args required.")
+	}
+
+	// 只有管理员可以迁移
+	if err := checkAdmin(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	result, err := stub.GetStateByPartialCompositeKey("order", []string{})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("query order error: %s", err))
+	}
+	defer result.Close()
+
+	migrated := 0
+	for result.HasNext() {
+		val, err := result.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("query orders error: %s", err))
+		}
+
+		order := new(Order)
+		if err := json.Unmarshal(val.GetValue(), order); err != nil {
+			return shim.Error(fmt.Sprintf("unmarshal error: %s", err))
+		}
+
+		status, statusOk := legacyStatusMap[order.Status]
+		previousStatus, previousOk := legacyStatusMap[order.PreviousStatus]
+		if !statusOk && !previousOk {
+			continue
+		}
+		if statusOk {
+			order.Status = status
+		}
+		if previousOk {
+			order.PreviousStatus = previousStatus
+		}
+
+		// 序列化对象
+		orderBytes, err := json.Marshal(order)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("marshal order error %s", err))
+		}
+
+		// 写入区块链账本
+		if err := stub.PutState(val.GetKey(), orderBytes); err != nil {
+			return shim.Error(fmt.Sprintf("put order error %s", err))
+		}
+		migrated++
+	}
+
+	if migrated != 0 {
+		if err := setEvent(stub, &Event{Type: EventOrderStatusMigrated, Count: migrated}); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	return shim.Success([]byte(fmt.Sprintf(`{"migrated":%d}`, migrated)))
+}
+
+// 计算订单结算明细
+// 扣款 = 最低温度偏差值 * 0.1 * 货物数量 + 最高温度偏差值 * 0.2 * 货物数量
+// 最低温度偏差值为记录到的最低温度低于约定最低温的差值，最高温度偏差值为记录到的最高温度高于约定最高温的差值
+// 结算只依赖账本中的订单数据，不读取节点本地时间，保证各背书节点计算结果一致
+func calculateSettlement(order *Order) *Settlement {
+	// 旧版本的订单没有数量，按1件货物计算
+	quantity := float64(order.Quantity)
+	if order.Quantity <= 0 {
+		quantity = 1
+	}
+
+	totalPrice := roundAmount(quantity * order.Commodity.Price)
+	settlement := &Settlement{TotalPrice: totalPrice}
+
+	for _, val := range order.TemperatureVariation {
+		if deviation := order.Commodity.LowTemperature - val.Temperature; deviation > settlement.LowDeviation {
+			settlement.LowDeviation = deviation
+		}
+		if deviation := val.Temperature - order.Commodity.HighTemperature; deviation > settlement.HighDeviation {
+			settlement.HighDeviation = deviation
+		}
+	}
+
+	// 金额保留两位小数
+	settlement.Deduction = roundAmount(settlement.LowDeviation*0.1*quantity + settlement.HighDeviation*0.2*quantity)
+	settlement.Payment = roundAmount(totalPrice - settlement.Deduction)
+	if settlement.Payment < 0 {
+		settlement.Payment = 0
+	}
+
+	// 物流费按总额的百分比或固定运费计算，最多不超过买家实付金额
+	if order.CarrierId != "" {
+		switch order.FreightType {
+		case freightTypeRate:
+			settlement.Freight = roundAmount(totalPrice * order.Freight / 100)
+		case freightTypeFixed:
+			settlement.Freight = order.Freight
+		}
+	}
+	if settlement.Freight > settlement.Payment {
+		settlement.Freight = settlement.Payment
+	}
+	settlement.SellerIncome = roundAmount(settlement.Payment - settlement.Freight)
+
+	return settlement
+}
+
+// 订单结算，从买家账户扣款，分别付给物流商和供货商
+// 买家余额不足时拒绝结算，所有账户在检查通过后才写入账本
+func settleOrder(stub shim.ChaincodeStubInterface, order *Order, settlement *Settlement) error {
+	transfers := []struct {
+		accountId string
+		amount    float64
+	}{
+		{order.BuyerId, -settlement.Payment},
+		{order.SellerId, settlement.SellerIncome},
+	}
+	if order.CarrierId != "" {
+		transfers = append(transfers, struct {
+			accountId string
+			amount    float64
+		}{order.CarrierId, settlement.Freight})
+	}
+
+	// 同一账户可能承担多个角色，按账户汇总后再写入
+	accounts := make(map[string]*Account)
+	keys := make(map[string]string)
+	ids := make([]string, 0)
+	for _, val := range transfers {
+		account, ok := accounts[val.accountId]
+		if !ok {
+			var key string
+			var err error
+			account, key, err = getAccount(stub, val.accountId)
+			if err != nil {
+				return err
+			}
+			accounts[val.accountId] = account
+			keys[val.accountId] = key
+			ids = append(ids, val.accountId)
+		}
+		account.Balance = roundAmount(account.Balance + val.amount)
+	}
+
+	if accounts[order.BuyerId].Balance < 0 {
+		return fmt.Errorf("buyer %s balance is insufficient", order.BuyerId)
+	}
+
+	for _, id := range ids {
+		if err := putAccount(stub, keys[id], accounts[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 解析参数中的时间，应用程序传入带时区的RFC3339格式并保留毫秒
+// 兼容旧版本的"2006-01-02 15:04:05"格式，按UTC解析
+func parseTime(val string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, val); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02 15:04:05", val)
+}
+
+// 获取交易时间，同一交易在各背书节点上得到的时间一致
+func getTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(timestamp.GetSeconds(), int64(timestamp.GetNanos())).UTC(), nil
+}
+
+// 金额四舍五入保留两位小数
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// 根据账户ID读取账户，同时返回账户的主键
+func getAccount(stub shim.ChaincodeStubInterface, accountId string) (*Account, string, error) {
+	key, err := stub.CreateCompositeKey("account", []string{accountId})
+	if err != nil {
+		return nil, "", fmt.Errorf("create key error %s", err)
+	}
+
+	accountBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, "", fmt.Errorf("get account error %s", err)
+	}
+	if len(accountBytes) == 0 {
+		return nil, "", fmt.Errorf("account %s not exists", accountId)
+	}
+
+	account := new(Account)
+	if err := json.Unmarshal(accountBytes, account); err != nil {
+		return nil, "", fmt.Errorf("unmarshal error: %s", err)
+	}
+
+	return account, key, nil
+}
+
+// 读取有效账户，账户不存在或已注销时返回错误
+func getActiveAccount(stub shim.ChaincodeStubInterface, accountId string) (*Account, error) {
+	account, _, err := getAccount(stub, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if account.Deactivated {
+		return nil, fmt.Errorf("account %s is deactivated", accountId)
+	}
+	return account, nil
+}
+
+// 账户角色，旧版本的账户按账户名推断
+func accountRole(account *Account) string {
+	if account.Role != "" {
+		return account.Role
+	}
+	return legacyRoleMap[account.Name]
+}
+
+// 检查角色是否合法
+func isValidRole(role string) bool {
+	for _, val := range roleList {
+		if val == role {
+			return true
+		}
+	}
+	return false
+}
+
+// 读取指定角色的有效账户
+func getAccountWithRole(stub shim.ChaincodeStubInterface, accountId string, role string) (*Account, error) {
+	account, err := getActiveAccount(stub, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if accountRole(account) != role {
+		return nil, fmt.Errorf("account %s is not a %s", accountId, role)
+	}
+	return account, nil
+}
+
+// 是否为订单的买家、卖家或物流商
+func isOrderParticipant(order *Order, accountId string) bool {
+	return accountId == order.BuyerId || accountId == order.SellerId || accountId == order.CarrierId
+}
+
+// 检查操作人是否为订单的物流商本人，旧版本的订单没有物流商时任意物流商都可以操作
+func checkOrderCarrier(stub shim.ChaincodeStubInterface, order *Order, operatorId string) error {
+	account, err := getAccountWithRole(stub, operatorId, roleCarrier)
+	if err != nil {
+		return err
+	}
+	if order.CarrierId != "" && order.CarrierId != operatorId {
+		return fmt.Errorf("account %s is not the carrier of order %s", operatorId, order.Id)
+	}
+	return checkCaller(stub, account)
+}
+
+// 检查操作人是否为订单的参与方本人，监管方只能查询
+func checkOrderParticipant(stub shim.ChaincodeStubInterface, order *Order, operatorId string) error {
+	account, err := getActiveAccount(stub, operatorId)
+	if err != nil {
+		return err
+	}
+	if accountRole(account) == roleRegulator || !isOrderParticipant(order, operatorId) {
+		return fmt.Errorf("account %s is not a participant of order %s", operatorId, order.Id)
+	}
+	return checkCaller(stub, account)
+}
+
+// 读取调用者的MSP ID和证书中的账户ID，证书中没有账户属性时账户ID为空
+func getCaller(stub shim.ChaincodeStubInterface) (string, string, error) {
+	identity, err := cid.New(stub)
+	if err != nil {
+		return "", "", fmt.Errorf("get client identity error %s", err)
+	}
+
+	mspId, err := identity.GetMSPID()
+	if err != nil {
+		return "", "", fmt.Errorf("get msp id error %s", err)
+	}
+
+	accountId, _, err := identity.GetAttributeValue(accountIdAttribute)
+	if err != nil {
+		return "", "", fmt.Errorf("get %s attribute error %s", accountIdAttribute, err)
+	}
+
+	return mspId, accountId, nil
+}
+
+// 调用者是否为管理员：启用NodeOU时组织管理员证书的OU为admin，或Fabric CA登记时账户角色属性为admin
+func isAdmin(stub shim.ChaincodeStubInterface) (bool, error) {
+	identity, err := cid.New(stub)
+	if err != nil {
+		return false, fmt.Errorf("get client identity error %s", err)
+	}
+
+	if ok, err := identity.HasOUValue(roleAdmin); err != nil {
+		return false, fmt.Errorf("get client ou error %s", err)
+	} else if ok {
+		return true, nil
+	}
+
+	role, _, err := identity.GetAttributeValue(accountRoleAttribute)
+	if err != nil {
+		return false, fmt.Errorf("get %s attribute error %s", accountRoleAttribute, err)
+	}
+	return role == roleAdmin, nil
+}
+
+// 检查调用者是否为管理员
+func checkAdmin(stub shim.ChaincodeStubInterface) error {
+	admin, err := isAdmin(stub)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("caller is not admin")
+	}
+	return nil
+}
+
+// 检查调用者是否为管理员或有效的监管方账户本人
+func checkAdminOrRegulator(stub shim.ChaincodeStubInterface) error {
+	if admin, err := isAdmin(stub); err != nil || admin {
+		return err
+	}
+	_, accountId, err := getCaller(stub)
+	if err != nil {
+		return err
+	}
+	account, err := getAccountWithRole(stub, accountId, roleRegulator)
+	if err != nil {
+		return fmt.Errorf("caller is not admin or regulator")
+	}
+	return checkCaller(stub, account)
+}
+
+// 按调用者证书确定查询人，管理员和监管方可以查询所有数据，返回nil；其他调用者返回其有效账户
+func getViewer(stub shim.ChaincodeStubInterface) (*Account, error) {
+	if admin, err := isAdmin(stub); err != nil || admin {
+		return nil, err
+	}
+	_, accountId, err := getCaller(stub)
+	if err != nil {
+		return nil, err
+	}
+	if accountId == "" {
+		return nil, fmt.Errorf("caller has no %s attribute", accountIdAttribute)
+	}
+	account, err := getActiveAccount(stub, accountId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCaller(stub, account); err != nil {
+		return nil, err
+	}
+	if accountRole(account) == roleRegulator {
+		return nil, nil
+	}
+	return account, nil
+}
+
+// 检查调用者是否为管理员或账户本人
+func checkAdminOrCaller(stub shim.ChaincodeStubInterface, account *Account) error {
+	if admin, err := isAdmin(stub); err != nil || admin {
+		return err
+	}
+	return checkCaller(stub, account)
+}
+
+// 检查调用者是否为账户本人：证书的账户属性与账户ID一致，且属于账户所在的组织
+func checkCaller(stub shim.ChaincodeStubInterface, account *Account) error {
+	mspId, accountId, err := getCaller(stub)
+	if err != nil {
+		return err
+	}
+	if accountId != account.Id {
+		return fmt.Errorf("caller is not account %s", account.Id)
+	}
+	if account.MspId != "" && account.MspId != mspId {
+		return fmt.Errorf("account %s does not belong to %s", account.Id, mspId)
+	}
+	return nil
+}
+
+// 写入账户
+func putAccount(stub shim.ChaincodeStubInterface, key string, account *Account) error {
+	// 序列化对象
+	accountBytes, err := json.Marshal(account)
+	if err != nil {
+		return fmt.Errorf("marshal account error %s", err)
+	}
+
+	if err := stub.PutState(key, accountBytes); err != nil {
+		return fmt.Errorf("put account error %s", err)
+	}
+	return nil
+}
+
+// 设置交易的链码事件，补全交易ID和交易时间
+func setEvent(stub shim.ChaincodeStubInterface, event *Event) error {
+	event.TxId = stub.GetTxID()
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return fmt.Errorf("get tx timestamp error %s", err)
+	}
+	event.Time = txTime
+
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event error %s", err)
+	}
+	if err := stub.SetEvent(event.Type, eventBytes); err != nil {
+		return fmt.Errorf("set event error %s", err)
+	}
+	return nil
+}
+
+// 根据订单ID读取订单，同时返回订单的主键
+func getOrder(stub shim.ChaincodeStubInterface, orderId string) (*Order, string, error) {
+	key, err := stub.CreateCompositeKey("order", []string{orderId})
+	if err != nil {
+		return nil, "", fmt.Errorf("create key error %s", err)
+	}
+
+	orderBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, "", fmt.Errorf("get order error %s", err)
+	}
+	if len(orderBytes) == 0 {
+		return nil, "", fmt.Errorf("order not exists")
+	}
+
+	order := new(Order)
+	if err := json.Unmarshal(orderBytes, order); err != nil {
+		return nil, "", fmt.Errorf("unmarshal error: %s", err)
+	}
+
+	return order, key, nil
+}
@@ -0,0 +1,1857 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package perishablefood
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+var mutex sync.RWMutex
+
+func expectApi(status int, testFunc string) {
+	str := "******"
+	file := "./chaincode_test_result.txt"
+	switch status {
+	case 1:
+		str += "测试通过：pass"
+		mutex.Lock()
+		writeFile(file, testFunc+":PASS\n")
+		mutex.Unlock()
+	case 2:
+		str += "测试失败：fail"
+		mutex.Lock()
+		writeFile(file, testFunc+":FAIL\n")
+		mutex.Unlock()
+	}
+	fmt.Println(str)
+}
+
+func writeFile(filePath string, data string) {
+	//os.Remove(filePath)
+	//os.Create(filePath)
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil && os.IsNotExist(err) {
+		fmt.Println("文件打开失败", err)
+		file, _ = os.Create(filePath)
+	}
+	//及时关闭file句柄
+	defer file.Close()
+	//写入文件时，使用带缓存的 *Writer
+	write := bufio.NewWriter(file)
+	write.WriteString(data)
+	//Flush将缓存的文件真正写入到文件中
+	write.Flush()
+}
+
+func GetNewStub() *shimtest.MockStub {
+	var scc = new(PerishableFood)
+	var stub = shimtest.NewMockStub("ex01", scc)
+	stub.Creator = newCreator(testMspId, "") // 由组织管理员初始化链码
+	return stub
+}
+
+// 链码初始化-检查商品初始化
+func Test_Init1(t *testing.T) {
+	stub := GetNewStub()
+	stub.MockInit("1", nil)
+	results, _ := stub.GetStateByPartialCompositeKey("commodity", []string{})
+	if results.HasNext() {
+		kv, _ := results.Next()
+		commodity := new(Commodity)
+		json.Unmarshal(kv.GetValue(), commodity)
+		if commodity.Id == "" {
+			expectApi(2, "Init1")
+			t.FailNow()
+		}
+		expectApi(1, "Init1")
+	} else {
+		expectApi(2, "Init1")
+		t.FailNow()
+	}
+}
+
+// 链码初始化-检查账户初始化
+func Test_Init2(t *testing.T) {
+	stub := GetNewStub()
+	stub.MockInit("1", nil)
+	results, _ := stub.GetStateByPartialCompositeKey("account", []string{"1"})
+	if results.HasNext() {
+		kv, _ := results.Next()
+		account := new(Account)
+		json.Unmarshal(kv.GetValue(), account)
+		if account.Id == "" || account.Balance == 0 {
+			expectApi(2, "Init2")
+			t.FailNow()
+		}
+		expectApi(1, "Init2")
+	} else {
+		expectApi(2, "Init2")
+		t.FailNow()
+	}
+}
+
+// 链码初始化-检查账户初始化
+func Test_Init3(t *testing.T) {
+	stub := GetNewStub()
+	stub.MockInit("1", nil)
+	results, _ := stub.GetStateByPartialCompositeKey("account", []string{"2"})
+	if results.HasNext() {
+		kv, _ := results.Next()
+		account := new(Account)
+		json.Unmarshal(kv.GetValue(), account)
+		if account.Id == "" || account.Balance == 0 {
+			expectApi(2, "Init3")
+			t.FailNow()
+		}
+		expectApi(1, "Init3")
+	} else {
+		expectApi(2, "Init3")
+		t.FailNow()
+	}
+}
+
+// 链码初始化-检查账户初始化
+func Test_Init4(t *testing.T) {
+	stub := GetNewStub()
+	stub.MockInit("1", nil)
+	results, _ := stub.GetStateByPartialCompositeKey("account", []string{"3"})
+	if results.HasNext() {
+		kv, _ := results.Next()
+		account := new(Account)
+		json.Unmarshal(kv.GetValue(), account)
+		if account.Id == "" || account.Balance == 0 {
+			expectApi(2, "Init4")
+			t.FailNow()
+		}
+		expectApi(1, "Init4")
+	} else {
+		expectApi(2, "Init4")
+		t.FailNow()
+	}
+}
+
+// 创建商品-参数非空校验
+func Test_createCommodity1(t *testing.T) {
+	stub := GetNewStub()
+	loopCheckError(stub, t, 1, 7, 1, "createCommodity", "1", "invalid args")
+}
+
+// 创建商品-参数个数校验
+func Test_createCommodity2(t *testing.T) {
+	stub := GetNewStub()
+	loopCheckError(stub, t, 8, 8, 1, "createCommodity", "2", "not enough args")
+}
+
+// 创建商品-检测是否已存在商品
+func Test_createCommodity3(t *testing.T) {
+	stub := GetNewStub()
+	loopCheckError(stub, t, 9, 9, 2, "createCommodity", "3", "commodity already exist")
+}
+
+// 创建商品-创建商品是否成功
+func Test_createCommodity4(t *testing.T) {
+	stub := GetNewStub()
+	res := testSomeTx(stub, 4, "createCommodity", 9)
+	if res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "createCommodity4")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"commodity", "20211001001"})
+	if res.Payload == nil {
+		expectApi(2, "createCommodity4")
+		t.FailNow()
+	}
+	expectApi(1, "createCommodity4")
+}
+
+// 新建订单-参数非空校验
+func Test_createOrder1(t *testing.T) {
+	stub := GetNewStub()
+	loopCheckError(stub, t, 1, 12, 2, "createOrder", "1", "invalid args")
+}
+
+// 新建订单-参数个数校验
+func Test_createOrder2(t *testing.T) {
+	stub := GetNewStub()
+	loopCheckError(stub, t, 13, 13, 2, "createOrder", "2", "not enough args")
+}
+
+// 新建订单-检测是否存在此商品
+func Test_createOrder3(t *testing.T) {
+	stub := GetNewStub()
+	loopCheckError(stub, t, 14, 14, 4, "createOrder", "3", "Commodity not exists")
+}
+
+// 新建订单-检测是否已存在订单
+func Test_createOrder4(t *testing.T) {
+	stub := GetNewStub()
+	loopCheckError(stub, t, 15, 15, 3, "createOrder", "4", "order already exists")
+}
+
+// 新建订单-创建订单是否成功
+func Test_createOrder5(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 1)
+	putStateTransaction(stub, 3)
+	res := invoke(stub, "1", GetTxArgs(stub, "createOrder", 15))
+	if res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "createOrder5")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"order", "20211001101"})
+	if res.Payload == nil {
+		expectApi(2, "createOrder5")
+		t.FailNow()
+	}
+	expectApi(1, "createOrder5")
+}
+
+// 新建订单-记录配送地址、预计送达时间和数量
+func Test_createOrder6(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 1)
+	putStateTransaction(stub, 3)
+	res := invoke(stub, "1", createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "7"))
+	if res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "createOrder6")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"order", "20211001101"})
+	order := new(Order)
+	_ = json.Unmarshal(res.Payload, order)
+	if order.Quantity == 7 && order.DeliverAddress == "五角场" && order.DeliverTime.Sub(order.OrderTime) == 72*time.Hour {
+		expectApi(1, "createOrder6")
+	} else {
+		expectApi(2, "createOrder6")
+		t.FailNow()
+	}
+}
+
+// 新建订单-数量必须为正整数，预计送达时间必须晚于下单时间，物流费必须合法
+func Test_createOrder7(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 1)
+	putStateTransaction(stub, 3)
+	invalidFreightType := createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "7")
+	invalidFreightType[11] = []byte("weight")
+	invalidFreightRate := createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "7")
+	invalidFreightRate[12] = []byte("120")
+	for _, args := range [][][]byte{
+		createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "0"),
+		createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "1.5"),
+		createOrderArgs("2021-10-01 08:00:00", "2021-09-30 08:00:00", "7"),
+		invalidFreightType,
+		invalidFreightRate,
+	} {
+		res := invoke(stub, "1", args)
+		if res.Status == shim.OK {
+			expectApi(2, "createOrder7")
+			t.FailNow()
+		}
+	}
+	expectApi(1, "createOrder7")
+}
+
+// 新建订单-买家、卖家和物流商必须是存在且有效的账户
+func Test_createOrder8(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 1)
+	putStateTransaction(stub, 3)
+	missingCarrier := createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "7")
+	missingCarrier[10] = []byte("99")
+	res := invoke(stub, "1", missingCarrier)
+	if res.Status == shim.OK {
+		expectApi(2, "createOrder8")
+		t.FailNow()
+	}
+
+	res = invoke(stub, "2", [][]byte{[]byte("deactivateAccount"), []byte("3")})
+	if res.Status != shim.OK {
+		expectApi(2, "createOrder8")
+		t.FailNow()
+	}
+	res = invoke(stub, "3", createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "7"))
+	if res.Status == shim.OK {
+		expectApi(2, "createOrder8")
+		t.FailNow()
+	}
+	expectApi(1, "createOrder8")
+}
+
+// 创建商品-所有者必须是存在的账户
+func Test_createCommodity5(t *testing.T) {
+	stub := GetNewStub()
+	res := invoke(stub, "1", [][]byte{
+		[]byte("createCommodity"),
+		[]byte("testBuy"),
+		[]byte("20211001001"),
+		[]byte("五角场"),
+		[]byte("-2"),
+		[]byte("0"),
+		[]byte("7.9"),
+		[]byte("1"),
+	})
+	if res.Status == shim.OK {
+		expectApi(2, "createCommodity5")
+		t.FailNow()
+	}
+
+	putStateTransaction(stub, 3)
+	res = invoke(stub, "2", [][]byte{
+		[]byte("createCommodity"),
+		[]byte("testBuy"),
+		[]byte("20211001001"),
+		[]byte("五角场"),
+		[]byte("-2"),
+		[]byte("0"),
+		[]byte("7.9"),
+		[]byte("1"),
+	})
+	if res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "createCommodity5")
+		t.FailNow()
+	}
+	expectApi(1, "createCommodity5")
+}
+
+// 创建账户-创建成功，账户ID不能重复，余额不能为负，角色必须合法
+func Test_createAccount(t *testing.T) {
+	stub := GetNewStub()
+	res := invoke(stub, "1", [][]byte{[]byte("createAccount"), []byte("5"), []byte("冷链物流"), []byte("500"), []byte("carrier")})
+	if res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "createAccount")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"account", "5"})
+	account := new(Account)
+	_ = json.Unmarshal(res.Payload, account)
+	if account.Name != "冷链物流" || account.Balance != 500 || account.Role != roleCarrier || account.Deactivated {
+		expectApi(2, "createAccount")
+		t.FailNow()
+	}
+
+	for _, args := range [][][]byte{
+		{[]byte("createAccount"), []byte("5"), []byte("重复账户"), []byte("500"), []byte("carrier")},
+		{[]byte("createAccount"), []byte("6"), []byte("负余额"), []byte("-1"), []byte("buyer")},
+		{[]byte("createAccount"), []byte("7"), []byte("未知角色"), []byte("500"), []byte("owner")},
+		{[]byte("createAccount"), []byte("8"), []byte("")},
+	} {
+		if res := invoke(stub, "2", args); res.Status == shim.OK {
+			expectApi(2, "createAccount")
+			t.FailNow()
+		}
+	}
+	expectApi(1, "createAccount")
+}
+
+// 创建账户-非管理员只能按证书中的账户ID和角色创建自己的账户，且不能带余额
+func Test_createAccount2(t *testing.T) {
+	stub := GetNewStub()
+	self := newCreatorWith(testMspId, pkix.Name{CommonName: "user5"}, map[string]string{accountIdAttribute: "5", accountRoleAttribute: roleCarrier})
+	for _, args := range [][][]byte{
+		{[]byte("createAccount"), []byte("5"), []byte("冷链物流"), []byte("500"), []byte("carrier")},
+		{[]byte("createAccount"), []byte("5"), []byte("冷链物流"), []byte("0"), []byte("regulator")},
+		{[]byte("createAccount"), []byte("6"), []byte("冷链物流"), []byte("0"), []byte("carrier")},
+	} {
+		if res := invokeWith(stub, "1", self, args); res.Status == shim.OK {
+			expectApi(2, "createAccount2")
+			t.FailNow()
+		}
+	}
+	if res := invokeAs(stub, "2", testMspId, "6", [][]byte{[]byte("createAccount"), []byte("6"), []byte("买家"), []byte("0"), []byte("buyer")}); res.Status == shim.OK {
+		expectApi(2, "createAccount2")
+		t.FailNow()
+	}
+
+	res := invokeWith(stub, "3", self, [][]byte{[]byte("createAccount"), []byte("5"), []byte("冷链物流"), []byte("0"), []byte("carrier")})
+	account := new(Account)
+	_ = json.Unmarshal(getTr(stub, []string{"account", "5"}).Payload, account)
+	if res.Status != shim.OK || account.Balance != 0 || account.Role != roleCarrier || account.MspId != testMspId {
+		t.Log(res.Message)
+		expectApi(2, "createAccount2")
+		t.FailNow()
+	}
+
+	// 证书的账户角色属性为admin时是管理员
+	admin := newCreatorWith(testMspId, pkix.Name{CommonName: "admin1"}, map[string]string{accountRoleAttribute: roleAdmin})
+	if res := invokeWith(stub, "4", admin, [][]byte{[]byte("createAccount"), []byte("7"), []byte("买家"), []byte("500"), []byte("buyer")}); res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "createAccount2")
+		t.FailNow()
+	}
+	expectApi(1, "createAccount2")
+}
+
+// 更新账户-修改账户名称，不存在的账户不能更新
+func Test_updateAccount(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 3)
+	res := invoke(stub, "1", [][]byte{[]byte("updateAccount"), []byte("2"), []byte("冷链物流")})
+	if res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "updateAccount")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"account", "2"})
+	account := new(Account)
+	_ = json.Unmarshal(res.Payload, account)
+	if account.Name != "冷链物流" || account.Balance != 1000 {
+		expectApi(2, "updateAccount")
+		t.FailNow()
+	}
+
+	res = invoke(stub, "2", [][]byte{[]byte("updateAccount"), []byte("99"), []byte("冷链物流")})
+	if res.Status == shim.OK {
+		expectApi(2, "updateAccount")
+		t.FailNow()
+	}
+
+	// 其他账户不能修改，管理员可以修改
+	res = invokeAs(stub, "3", testMspId, "1", [][]byte{[]byte("updateAccount"), []byte("2"), []byte("物流商")})
+	if res.Status == shim.OK {
+		expectApi(2, "updateAccount")
+		t.FailNow()
+	}
+	res = invokeAs(stub, "4", testMspId, "", [][]byte{[]byte("updateAccount"), []byte("2"), []byte("物流商")})
+	if res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "updateAccount")
+		t.FailNow()
+	}
+	expectApi(1, "updateAccount")
+}
+
+// 注销账户-注销后不能重复注销，也不能再更新
+func Test_deactivateAccount(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 3)
+	res := invoke(stub, "1", [][]byte{[]byte("deactivateAccount"), []byte("2")})
+	if res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "deactivateAccount")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"account", "2"})
+	account := new(Account)
+	_ = json.Unmarshal(res.Payload, account)
+	if !account.Deactivated {
+		expectApi(2, "deactivateAccount")
+		t.FailNow()
+	}
+
+	for _, args := range [][][]byte{
+		{[]byte("deactivateAccount"), []byte("2")},
+		{[]byte("updateAccount"), []byte("2"), []byte("冷链物流")},
+	} {
+		if res := invoke(stub, "2", args); res.Status == shim.OK {
+			expectApi(2, "deactivateAccount")
+			t.FailNow()
+		}
+	}
+
+	// 其他账户不能注销账户，监管方可以注销
+	deactivate := [][]byte{[]byte("deactivateAccount"), []byte("3")}
+	if res := invokeAs(stub, "3", testMspId, "1", deactivate); res.Status == shim.OK {
+		expectApi(2, "deactivateAccount")
+		t.FailNow()
+	}
+	if res := invokeAs(stub, "4", testMspId, "4", deactivate); res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "deactivateAccount")
+		t.FailNow()
+	}
+	expectApi(1, "deactivateAccount")
+}
+
+// 链码初始化-初始化账户的角色
+func Test_Init5(t *testing.T) {
+	stub := GetNewStub()
+	stub.MockInit("1", nil)
+	for id, role := range map[string]string{"1": roleSupplier, "2": roleCarrier, "3": roleBuyer, "4": roleRegulator} {
+		res := getTr(stub, []string{"account", id})
+		account := new(Account)
+		_ = json.Unmarshal(res.Payload, account)
+		if account.Role != role || account.MspId != testMspId {
+			expectApi(2, "Init5")
+			t.FailNow()
+		}
+	}
+
+	// 初始账户属于初始化链码的组织，其他组织的同名账户属性无效
+	args := [][]byte{[]byte("createCommodity"), []byte("testBuy"), []byte("20211001001"), []byte("五角场"), []byte("-2"), []byte("0"), []byte("7.9"), []byte("1")}
+	if res := invokeAs(stub, "2", "Organization2MSP", "1", args); res.Status == shim.OK {
+		expectApi(2, "Init5")
+		t.FailNow()
+	}
+	if res := invokeAs(stub, "3", testMspId, "1", args); res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "Init5")
+		t.FailNow()
+	}
+	expectApi(1, "Init5")
+}
+
+// 账户角色-旧版本账户按账户名推断角色
+func Test_accountRole(t *testing.T) {
+	if accountRole(&Account{Name: "物流商"}) == roleCarrier &&
+		accountRole(&Account{Name: "买家"}) == roleBuyer &&
+		accountRole(&Account{Name: "买家", Role: roleRegulator}) == roleRegulator &&
+		accountRole(&Account{Name: "冷链物流"}) == "" {
+		expectApi(1, "accountRole")
+	} else {
+		expectApi(2, "accountRole")
+		t.FailNow()
+	}
+}
+
+// 创建商品-只有供货商才能创建商品
+func Test_createCommodity6(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 3)
+	res := invoke(stub, "1", [][]byte{
+		[]byte("createCommodity"),
+		[]byte("testBuy"),
+		[]byte("20211001001"),
+		[]byte("五角场"),
+		[]byte("-2"),
+		[]byte("0"),
+		[]byte("7.9"),
+		[]byte("3"),
+	})
+	if res.Status == shim.OK {
+		expectApi(2, "createCommodity6")
+		t.FailNow()
+	}
+	expectApi(1, "createCommodity6")
+}
+
+// 新建订单-买家、卖家和物流商必须是对应的角色
+func Test_createOrder9(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 1)
+	putStateTransaction(stub, 3)
+	// 参数依次为买家、卖家、物流商
+	for i, id := range map[int]string{5: "1", 6: "3", 10: "4"} {
+		args := createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "7")
+		args[i] = []byte(id)
+		if res := invoke(stub, "1", args); res.Status == shim.OK {
+			expectApi(2, "createOrder9")
+			t.FailNow()
+		}
+	}
+	expectApi(1, "createOrder9")
+}
+
+// 更新订单温度列表-只有订单的物流商才能上传温度
+func Test_updateOrderTemperature6(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 4)
+	res := invoke(stub, "0", [][]byte{[]byte("createAccount"), []byte("5"), []byte("冷链物流"), []byte("0"), []byte("carrier")})
+	if res.Status != shim.OK {
+		expectApi(2, "updateOrderTemperature6")
+		t.FailNow()
+	}
+	for _, operatorId := range []string{"3", "4", "5"} {
+		res := invoke(stub, "1", [][]byte{
+			[]byte("updateOrderTemperature"),
+			[]byte("20211001101"),
+			[]byte("26"),
+			[]byte("2021-10-02 10:00:00"),
+			[]byte(operatorId),
+		})
+		if res.Status == shim.OK {
+			expectApi(2, "updateOrderTemperature6")
+			t.FailNow()
+		}
+	}
+	expectApi(1, "updateOrderTemperature6")
+}
+
+// 更新订单温度列表-非运送中的订单返回固定的状态码，应用程序据此停止温度更新器
+func Test_updateOrderTemperature7(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 2)
+	res := invoke(stub, "1", [][]byte{
+		[]byte("updateOrderTemperature"),
+		[]byte("20211001101"),
+		[]byte("26"),
+		[]byte("2021-10-02 10:00:00"),
+		[]byte("2"),
+	})
+	if res.Status != StatusOrderClosed {
+		expectApi(2, "updateOrderTemperature7")
+		t.Fatalf("expected status %d, got %d %s", StatusOrderClosed, res.Status, res.Message)
+	}
+	expectApi(1, "updateOrderTemperature7")
+}
+
+// 更新订单温度列表-带时区的RFC3339时间转换为UTC并保留毫秒
+func Test_updateOrderTemperature8(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 4)
+	res := invoke(stub, "1", [][]byte{
+		[]byte("updateOrderTemperature"),
+		[]byte("20211001101"),
+		[]byte("26"),
+		[]byte("2021-10-02T10:00:00.123+08:00"),
+		[]byte("2"),
+	})
+	if res.Status != shim.OK {
+		expectApi(2, "updateOrderTemperature8")
+		t.Fatal(res.Message)
+	}
+	order := new(Order)
+	_ = json.Unmarshal(getTr(stub, []string{"order", "20211001101"}).Payload, order)
+	expected := time.Date(2021, 10, 2, 2, 0, 0, 123e6, time.UTC)
+	if len(order.TemperatureVariation) != 1 || !order.TemperatureVariation[0].RecordTime.Equal(expected) {
+		expectApi(2, "updateOrderTemperature8")
+		t.Fatalf("expected record time %s, got %+v", expected, order.TemperatureVariation)
+	}
+	expectApi(1, "updateOrderTemperature8")
+}
+
+// 更新订单状态-只有订单的物流商才能开始运送，监管方不能变更订单状态
+func Test_updateOrderStatus13(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 2)
+	for _, args := range [][]string{{"Processing", "3"}, {"Processing", "1"}, {"Canceled", "4"}} {
+		res := invoke(stub, "1", [][]byte{
+			[]byte("updateOrderStatus"),
+			[]byte("20211001101"),
+			[]byte(args[0]),
+			[]byte(args[1]),
+		})
+		if res.Status == shim.OK {
+			expectApi(2, "updateOrderStatus13")
+			t.FailNow()
+		}
+	}
+	expectApi(1, "updateOrderStatus13")
+}
+
+// 查询订单列表-管理员和监管方可以查询所有订单，其他账户只能查询自己参与的订单
+func Test_queryOrderList4(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 2)
+	res := invoke(stub, "0", [][]byte{[]byte("createAccount"), []byte("5"), []byte("冷链物流"), []byte("0"), []byte("carrier")})
+	if res.Status != shim.OK {
+		expectApi(2, "queryOrderList4")
+		t.FailNow()
+	}
+	// 查询人由证书确定，管理员也可以查询所有订单
+	for viewer, count := range map[string]int{"": 1, "4": 1, "3": 1, "5": 0} {
+		res := invokeAs(stub, "1", testMspId, viewer, [][]byte{[]byte("queryOrderList")})
+		orders := make([]*Order, 0)
+		_ = json.Unmarshal(res.Payload, &orders)
+		if res.Status != shim.OK || len(orders) != count {
+			expectApi(2, "queryOrderList4")
+			t.FailNow()
+		}
+	}
+
+	// 不能在参数中指定查询人，证书中没有账户属性的非管理员不能查询
+	if res := invokeAs(stub, "2", testMspId, "5", [][]byte{[]byte("queryOrderList"), []byte(""), []byte("4")}); res.Status == shim.OK {
+		expectApi(2, "queryOrderList4")
+		t.FailNow()
+	}
+	member := newCreatorWith(testMspId, pkix.Name{CommonName: "member"}, nil)
+	if res := invokeWith(stub, "3", member, [][]byte{[]byte("queryOrderList")}); res.Status == shim.OK {
+		expectApi(2, "queryOrderList4")
+		t.FailNow()
+	}
+	expectApi(1, "queryOrderList4")
+}
+
+// 查询账户列表-管理员和监管方可以查询所有账户，其他账户只能查询自己
+func Test_queryAccount4(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 3)
+	for _, args := range [][]string{{"all", "", "4"}, {"all", "4", "4"}, {"all", "3", "1"}, {"3", "3", "1"}} {
+		res := invokeAs(stub, "1", testMspId, args[1], [][]byte{[]byte("queryAccount"), []byte(args[0])})
+		accounts := make([]*Account, 0)
+		_ = json.Unmarshal(res.Payload, &accounts)
+		if res.Status != shim.OK || strconv.Itoa(len(accounts)) != args[2] {
+			expectApi(2, "queryAccount4")
+			t.FailNow()
+		}
+	}
+	res := invokeAs(stub, "1", testMspId, "3", [][]byte{[]byte("queryAccount"), []byte("1")})
+	if res.Status == shim.OK {
+		expectApi(2, "queryAccount4")
+		t.FailNow()
+	}
+	expectApi(1, "queryAccount4")
+}
+
+// 调用者身份-只能以证书中账户属性对应的账户身份操作
+func Test_checkCaller1(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 1)
+	putStateTransaction(stub, 3)
+	args := createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "7")
+	// 卖家代替买家下单、证书中没有账户属性时都拒绝
+	for _, accountId := range []string{"1", ""} {
+		if res := invokeAs(stub, "1", testMspId, accountId, args); res.Status == shim.OK {
+			expectApi(2, "checkCaller1")
+			t.FailNow()
+		}
+	}
+
+	stub = GetNewStub()
+	putStateTransaction(stub, 4)
+	res := invokeAs(stub, "1", testMspId, "2", [][]byte{
+		[]byte("updateOrderStatus"),
+		[]byte("20211001101"),
+		[]byte("Done"),
+		[]byte("3"),
+	})
+	if res.Status == shim.OK {
+		expectApi(2, "checkCaller1")
+		t.FailNow()
+	}
+	expectApi(1, "checkCaller1")
+}
+
+// 调用者身份-账户绑定创建它的组织，其他组织的同名账户属性无效
+func Test_checkCaller2(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 1)
+	putStateTransaction(stub, 3)
+	res := invokeAs(stub, "1", "Organization2MSP", "", [][]byte{
+		[]byte("createAccount"),
+		[]byte("5"),
+		[]byte("买家2"),
+		[]byte("500"),
+		[]byte("buyer"),
+	})
+	account := new(Account)
+	_ = json.Unmarshal(getTr(stub, []string{"account", "5"}).Payload, account)
+	if res.Status != shim.OK || account.MspId != "Organization2MSP" {
+		expectApi(2, "checkCaller2")
+		t.FailNow()
+	}
+
+	args := createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "7")
+	args[5] = []byte("5")
+	if res := invokeAs(stub, "2", testMspId, "5", args); res.Status == shim.OK {
+		expectApi(2, "checkCaller2")
+		t.FailNow()
+	}
+	if res := invokeAs(stub, "3", "Organization2MSP", "5", args); res.Status != shim.OK {
+		t.Log(res.Message)
+		expectApi(2, "checkCaller2")
+		t.FailNow()
+	}
+	expectApi(1, "checkCaller2")
+}
+
+// 查询商品列表-查询成功
+func Test_queryCommodityList(t *testing.T) {
+	stub := GetNewStub()
+
+	putStateTransaction(stub, 1)
+
+	resp := invoke(stub, "1", [][]byte{
+		[]byte("queryCommodityList"),
+	})
+	t.Log(resp.Message)
+	if len(resp.Payload) == 0 || bytes.NewBuffer(resp.Payload).String() == "[]" || resp.Status == shim.ERRORTHRESHOLD || resp.Status == shim.ERROR {
+		expectApi(2, "queryCommodityList")
+		t.FailNow()
+	} else {
+		expectApi(1, "queryCommodityList")
+	}
+}
+
+// 查询订单列表-参数非空校验
+func Test_queryOrderList1(t *testing.T) {
+	stub := GetNewStub()
+
+	putStateTransaction(stub, 2)
+
+	resp := invoke(stub, "1", [][]byte{
+		[]byte("queryOrderList"),
+		[]byte(""),
+	})
+	t.Log(resp.Message)
+	if len(resp.Payload) == 0 || bytes.NewBuffer(resp.Payload).String() == "[]" || resp.Status == shim.ERRORTHRESHOLD || resp.Status == shim.ERROR {
+		expectApi(1, "queryOrderList1")
+	} else {
+		expectApi(2, "queryOrderList1")
+		t.FailNow()
+	}
+}
+
+// 查询订单列表-参数个数校验
+func Test_queryOrderList2(t *testing.T) {
+	stub := GetNewStub()
+
+	putStateTransaction(stub, 2)
+
+	resp := invoke(stub, "1", [][]byte{
+		[]byte("queryOrderList"),
+		[]byte("1"),
+		[]byte("2"),
+	})
+	t.Log(resp.Message)
+	if len(resp.Payload) == 0 || bytes.NewBuffer(resp.Payload).String() == "[]" || resp.Status == shim.ERRORTHRESHOLD || resp.Status == shim.ERROR {
+		expectApi(1, "queryOrderList2")
+	} else {
+		expectApi(2, "queryOrderList2")
+		t.FailNow()
+	}
+}
+
+// 查询订单列表-查询成功
+func Test_queryOrderList3(t *testing.T) {
+	stub := GetNewStub()
+
+	putStateTransaction(stub, 2)
+
+	resp := invoke(stub, "1", [][]byte{
+		[]byte("queryOrderList"),
+		[]byte("20211001101"),
+	})
+	t.Log(resp.Message)
+	if len(resp.Payload) == 0 || bytes.NewBuffer(resp.Payload).String() == "[]" || resp.Status == shim.ERRORTHRESHOLD || resp.Status == shim.ERROR {
+		expectApi(2, "queryOrderList3")
+		t.FailNow()
+	} else {
+		expectApi(1, "queryOrderList3")
+	}
+}
+
+// 查询账户列表-参数非空校验
+func Test_queryAccount1(t *testing.T) {
+	stub := GetNewStub()
+
+	putStateTransaction(stub, 3)
+
+	resp := invoke(stub, "1", [][]byte{
+		[]byte("queryAccount"),
+		[]byte(""),
+	})
+	t.Log(resp.Message)
+	if len(resp.Payload) == 0 || bytes.NewBuffer(resp.Payload).String() == "[]" || resp.Status == shim.ERRORTHRESHOLD || resp.Status == shim.ERROR {
+		expectApi(1, "queryAccount1")
+	} else {
+		expectApi(2, "queryAccount1")
+		t.FailNow()
+	}
+}
+
+// 查询账户列表-参数个数校验
+func Test_queryAccount2(t *testing.T) {
+	stub := GetNewStub()
+
+	putStateTransaction(stub, 3)
+
+	resp := invoke(stub, "1", [][]byte{
+		[]byte("queryAccount"),
+		[]byte("111"),
+		[]byte("2222"),
+	})
+	t.Log(resp.Message)
+	if len(resp.Payload) == 0 || bytes.NewBuffer(resp.Payload).String() == "[]" || resp.Status == shim.ERRORTHRESHOLD || resp.Status == shim.ERROR {
+		expectApi(1, "queryAccount2")
+	} else {
+		expectApi(2, "queryAccount2")
+		t.FailNow()
+	}
+}
+
+// 查询账户列表-查询成功
+func Test_queryAccount3(t *testing.T) {
+	stub := GetNewStub()
+
+	putStateTransaction(stub, 3)
+
+	resp := invoke(stub, "1", [][]byte{
+		[]byte("queryAccount"),
+		[]byte("1"),
+	})
+	t.Log(resp.Message)
+	if len(resp.Payload) == 0 || bytes.NewBuffer(resp.Payload).String() == "[]" || resp.Status == shim.ERRORTHRESHOLD || resp.Status == shim.ERROR {
+		expectApi(2, "queryAccount3")
+		t.FailNow()
+	} else {
+		expectApi(1, "queryAccount3")
+	}
+}
+
+// 更新订单温度列表-参数非空校验
+func Test_updateOrderTemperature1(t *testing.T) {
+	stub := GetNewStub()
+	loopCheck(stub, t, 1, 3, 3, "updateOrderTemperature", "1")
+}
+
+// 更新订单温度列表-参数个数校验
+func Test_updateOrderTemperature2(t *testing.T) {
+	stub := GetNewStub()
+	loopCheck(stub, t, 4, 4, 3, "updateOrderTemperature", "2")
+}
+
+// 更新订单温度列表-更新成功
+func Test_updateOrderTemperature3(t *testing.T) {
+	stub := GetNewStub()
+	res := testSomeTx(stub, 5, "updateOrderTemperature", 5)
+	if res.Status != shim.OK {
+		expectApi(2, "updateOrderTemperature3")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"order", "20211001101"})
+	order := new(Order)
+	_ = json.Unmarshal(res.Payload, order)
+	if len(order.TemperatureVariation) == 1 && order.TemperatureVariation[0].Temperature == 26 {
+		expectApi(1, "updateOrderTemperature3")
+	} else {
+		expectApi(2, "updateOrderTemperature3")
+		t.FailNow()
+	}
+}
+
+// 更新订单温度列表-非运送中的订单拒绝记录
+func Test_updateOrderTemperature4(t *testing.T) {
+	stub := GetNewStub()
+	loopCheck(stub, t, 5, 5, 3, "updateOrderTemperature", "4")
+}
+
+// 更新订单温度列表-记录传感器ID
+func Test_updateOrderTemperature5(t *testing.T) {
+	stub := GetNewStub()
+	res := testSomeTx(stub, 5, "updateOrderTemperature", 6)
+	if res.Status != shim.OK {
+		expectApi(2, "updateOrderTemperature5")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"order", "20211001101"})
+	order := new(Order)
+	_ = json.Unmarshal(res.Payload, order)
+	if len(order.TemperatureVariation) == 1 && order.TemperatureVariation[0].SensorId == "s1" {
+		expectApi(1, "updateOrderTemperature5")
+	} else {
+		expectApi(2, "updateOrderTemperature5")
+		t.FailNow()
+	}
+}
+
+// 更新订单状态-参数非空校验
+func Test_updateOrderStatus1(t *testing.T) {
+	stub := GetNewStub()
+	loopCheck(stub, t, 1, 2, 3, "updateOrderStatus", "1")
+}
+
+// 更新订单状态-参数个数校验
+func Test_updateOrderStatus2(t *testing.T) {
+	stub := GetNewStub()
+	loopCheck(stub, t, 3, 3, 3, "updateOrderStatus", "2")
+}
+
+// 更新订单状态-更新状态成功
+func Test_updateOrderStatus3(t *testing.T) {
+	stub := GetNewStub()
+	res := testSomeTx(stub, 3, "updateOrderStatus", 4)
+	if res.Status != shim.OK {
+		expectApi(2, "updateOrderStatus3")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"order", "20211001101"})
+	order := new(Order)
+	_ = json.Unmarshal(res.Payload, order)
+	if order.Status == "Processing" {
+		expectApi(1, "updateOrderStatus3")
+	} else {
+		expectApi(2, "updateOrderStatus3")
+		t.FailNow()
+	}
+}
+
+// 更新订单状态-订单完成时买家扣款成功
+func Test_updateOrderStatus4(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 3)
+	putStateTransaction(stub, 4)
+
+	_ = invoke(stub, "1", [][]byte{
+		[]byte("updateOrderStatus"),
+		[]byte("20211001101"),
+		[]byte("Done"),
+		[]byte("3"),
+	})
+	key, _ := stub.CreateCompositeKey("account", []string{"3"})
+	value, _ := stub.GetState(key)
+	acc := new(Account)
+	_ = json.Unmarshal(value, acc)
+	if acc.Balance == 930 {
+		expectApi(1, "updateOrderStatus4")
+	} else {
+		expectApi(2, "updateOrderStatus4")
+		t.FailNow()
+	}
+}
+
+// 更新订单状态-订单完成时供应商收款成功
+func Test_updateOrderStatus5(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 3)
+	putStateTransaction(stub, 4)
+
+	_ = invoke(stub, "1", [][]byte{
+		[]byte("updateOrderStatus"),
+		[]byte("20211001101"),
+		[]byte("Done"),
+		[]byte("3"),
+	})
+	key, _ := stub.CreateCompositeKey("account", []string{"1"})
+	value, _ := stub.GetState(key)
+	acc := new(Account)
+	_ = json.Unmarshal(value, acc)
+	if acc.Balance == 1056 {
+		expectApi(1, "updateOrderStatus5")
+	} else {
+		expectApi(2, "updateOrderStatus5")
+		t.FailNow()
+	}
+}
+
+// 更新订单状态-订单完成时物流商收款成功
+func Test_updateOrderStatus6(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 3)
+	putStateTransaction(stub, 4)
+
+	_ = invoke(stub, "1", [][]byte{
+		[]byte("updateOrderStatus"),
+		[]byte("20211001101"),
+		[]byte("Done"),
+		[]byte("3"),
+	})
+	key, _ := stub.CreateCompositeKey("account", []string{"2"})
+	value, _ := stub.GetState(key)
+	acc := new(Account)
+	_ = json.Unmarshal(value, acc)
+	if acc.Balance == 1014 {
+		expectApi(1, "updateOrderStatus6")
+	} else {
+		expectApi(2, "updateOrderStatus6")
+		t.FailNow()
+	}
+}
+
+// 更新订单状态-买家余额不足时拒绝结算，各账户余额不变
+func Test_updateOrderStatus12(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 3)
+	putStateTransaction(stub, 4)
+
+	// 买家余额不足以支付70
+	key, _ := stub.CreateCompositeKey("account", []string{"3"})
+	accountBytes, _ := json.Marshal(&Account{Id: "3", Name: "买家", Balance: 50})
+	stub.MockTransactionStart("0")
+	_ = stub.PutState(key, accountBytes)
+	stub.MockTransactionEnd("0")
+
+	res := invoke(stub, "1", [][]byte{
+		[]byte("updateOrderStatus"),
+		[]byte("20211001101"),
+		[]byte("Done"),
+		[]byte("3"),
+	})
+	balances := make([]float64, 0)
+	for _, id := range []string{"1", "2", "3"} {
+		key, _ := stub.CreateCompositeKey("account", []string{id})
+		value, _ := stub.GetState(key)
+		acc := new(Account)
+		_ = json.Unmarshal(value, acc)
+		balances = append(balances, acc.Balance)
+	}
+	if res.Status == shim.ERROR && balances[0] == 1000 && balances[1] == 1000 && balances[2] == 50 {
+		expectApi(1, "updateOrderStatus12")
+	} else {
+		expectApi(2, "updateOrderStatus12")
+		t.FailNow()
+	}
+}
+
+// 订单结算-固定运费时物流商和供货商分账
+func Test_calculateSettlement2(t *testing.T) {
+	order := &Order{
+		Commodity:   &Commodity{Price: 10},
+		Quantity:    7,
+		CarrierId:   "2",
+		FreightType: "fixed",
+		Freight:     15,
+	}
+	settlement := calculateSettlement(order)
+	if settlement.Payment == 70 && settlement.Freight == 15 && settlement.SellerIncome == 55 {
+		expectApi(1, "calculateSettlement2")
+	} else {
+		expectApi(2, "calculateSettlement2")
+		t.FailNow()
+	}
+}
+
+// 订单结算-温度超出约定范围时扣款
+func Test_calculateSettlement(t *testing.T) {
+	order := &Order{
+		Commodity: &Commodity{
+			LowTemperature:  -2,
+			HighTemperature: 0,
+			Price:           10,
+		},
+		Quantity: 10,
+		TemperatureVariation: []*Temperature{
+			{Temperature: -1},
+			{Temperature: -5},
+			{Temperature: 3},
+		},
+	}
+	settlement := calculateSettlement(order)
+	if settlement.TotalPrice == 100 && settlement.LowDeviation == 3 && settlement.HighDeviation == 3 && settlement.Deduction == 9 && settlement.Payment == 91 {
+		expectApi(1, "calculateSettlement")
+	} else {
+		t.Logf("settlement: %+v", settlement)
+		expectApi(2, "calculateSettlement")
+		t.FailNow()
+	}
+}
+
+// 更新订单状态-运送中时绑定温度传感器
+func Test_updateOrderStatus7(t *testing.T) {
+	stub := GetNewStub()
+	res := testSomeTx(stub, 3, "updateOrderStatus", 6)
+	if res.Status != shim.OK {
+		expectApi(2, "updateOrderStatus7")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"order", "20211001101"})
+	order := new(Order)
+	_ = json.Unmarshal(res.Payload, order)
+	if order.SensorId == "s1" {
+		expectApi(1, "updateOrderStatus7")
+	} else {
+		expectApi(2, "updateOrderStatus7")
+		t.FailNow()
+	}
+}
+
+// 更新订单状态-不支持的状态
+func Test_updateOrderStatus8(t *testing.T) {
+	stub := GetNewStub()
+	loopCheck(stub, t, 7, 7, 3, "updateOrderStatus", "8")
+}
+
+// 更新订单状态-订单不存在
+func Test_updateOrderStatus9(t *testing.T) {
+	stub := GetNewStub()
+	loopCheck(stub, t, 8, 8, 3, "updateOrderStatus", "9")
+	res := getTr(stub, []string{"order", "20211001999"})
+	if len(res.Payload) != 0 {
+		expectApi(2, "updateOrderStatus9")
+		t.FailNow()
+	}
+}
+
+// 更新订单状态-非法的状态转换
+func Test_updateOrderStatus10(t *testing.T) {
+	stub := GetNewStub()
+	// 新建的订单不能直接完成
+	loopCheck(stub, t, 5, 5, 3, "updateOrderStatus", "10")
+
+	// 已完成的订单不能再次完成
+	stub = GetNewStub()
+	putStateTransaction(stub, 3)
+	putStateTransaction(stub, 4)
+	res := invoke(stub, "1", GetTxArgs(stub, "updateOrderStatus", 5))
+	if res.Status != shim.OK {
+		expectApi(2, "updateOrderStatus10")
+		t.FailNow()
+	}
+	key, _ := stub.CreateCompositeKey("account", []string{"3"})
+	before, _ := stub.GetState(key)
+	res = invoke(stub, "1", GetTxArgs(stub, "updateOrderStatus", 5))
+	after, _ := stub.GetState(key)
+	if res.Status == shim.OK || !bytes.Equal(before, after) {
+		expectApi(2, "updateOrderStatus10")
+		t.FailNow()
+	}
+	expectApi(1, "updateOrderStatus10")
+}
+
+// 更新订单状态-记录上一个状态和变更时间
+func Test_updateOrderStatus11(t *testing.T) {
+	stub := GetNewStub()
+	res := testSomeTx(stub, 3, "updateOrderStatus", 9)
+	if res.Status != shim.OK {
+		expectApi(2, "updateOrderStatus11")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"order", "20211001101"})
+	order := new(Order)
+	_ = json.Unmarshal(res.Payload, order)
+	if order.Status == enumStatus.Canceled && order.PreviousStatus == enumStatus.New && !order.StatusTime.IsZero() {
+		expectApi(1, "updateOrderStatus11")
+	} else {
+		expectApi(2, "updateOrderStatus11")
+		t.FailNow()
+	}
+}
+
+// 迁移订单状态-中文状态改写为状态码
+func Test_migrateOrderStatus(t *testing.T) {
+	stub := GetNewStub()
+	stub.MockTransactionStart("1")
+	order := &Order{Id: "20211001101", Status: "运送中", PreviousStatus: "新建", BuyerId: "3", SellerId: "1"}
+	orderBytes, _ := json.Marshal(order)
+	orderCompositeKey, _ := stub.CreateCompositeKey("order", []string{order.Id})
+	_ = stub.PutState(orderCompositeKey, orderBytes)
+	stub.MockTransactionEnd("1")
+
+	// 只有管理员可以迁移
+	if res := invokeAs(stub, "1", testMspId, "4", [][]byte{[]byte("migrateOrderStatus")}); res.Status == shim.OK {
+		expectApi(2, "migrateOrderStatus")
+		t.FailNow()
+	}
+	res := invoke(stub, "1", [][]byte{[]byte("migrateOrderStatus")})
+	if res.Status != shim.OK || string(res.Payload) != `{"migrated":1}` {
+		expectApi(2, "migrateOrderStatus")
+		t.FailNow()
+	}
+	res = getTr(stub, []string{"order", "20211001101"})
+	_ = json.Unmarshal(res.Payload, order)
+	if order.Status == enumStatus.Processing && order.PreviousStatus == enumStatus.New {
+		expectApi(1, "migrateOrderStatus")
+	} else {
+		expectApi(2, "migrateOrderStatus")
+		t.FailNow()
+	}
+}
+
+func putStateTransaction(stub *shimtest.MockStub, status int) {
+	stub.MockTransactionStart("1")
+	defer stub.MockTransactionEnd("1")
+
+	switch status {
+	case 1: // 买家购买商品
+		commodity := &Commodity{
+			Name:     "testBuy",
+			Id:       "20211001001",
+			Location: "五角场",
+			Price:    7.9, //单价
+			OwnerId:  "1",
+		}
+		bytes, _ := json.Marshal(commodity)
+		compositeKey, _ := stub.CreateCompositeKey("commodity", []string{commodity.Id})
+		_ = stub.PutState(compositeKey, bytes)
+	case 2:
+		putAccounts(stub)
+		commodity := &Commodity{
+			Name:     "testBuy",
+			Id:       "20211001001",
+			Location: "五角场",
+			Price:    7.8, //单价
+			OwnerId:  "1",
+		}
+		bytes, _ := json.Marshal(commodity)
+		compositeKey, _ := stub.CreateCompositeKey("commodity", []string{commodity.Id})
+		_ = stub.PutState(compositeKey, bytes)
+		order := &Order{
+			Commodity:   commodity,
+			Id:          "20211001101",
+			OrderTime:   time.Now(),
+			Quantity:    7,
+			Status:      enumStatus.New,
+			BuyerId:     "3",
+			SellerId:    "1",
+			CarrierId:   "2",
+			FreightType: "rate",
+			Freight:     20,
+		}
+		orderBytes, _ := json.Marshal(order)
+		orderCompositeKey, _ := stub.CreateCompositeKey("order", []string{order.Id})
+		_ = stub.PutState(orderCompositeKey, orderBytes)
+	case 3:
+		putAccounts(stub)
+	case 4:
+		putAccounts(stub)
+		commodity := &Commodity{
+			Name:     "testBuy",
+			Id:       "20211001001",
+			Location: "五角场",
+			Price:    10, //单价
+			OwnerId:  "1",
+		}
+		bytes, _ := json.Marshal(commodity)
+		compositeKey, _ := stub.CreateCompositeKey("commodity", []string{commodity.Id})
+		_ = stub.PutState(compositeKey, bytes)
+
+		order := &Order{
+			Commodity:   commodity,
+			Id:          "20211001101",
+			OrderTime:   time.Now(),
+			Quantity:    7,
+			Status:      enumStatus.Processing,
+			BuyerId:     "3",
+			SellerId:    "1",
+			CarrierId:   "2",
+			FreightType: "rate",
+			Freight:     20,
+		}
+		orderBytes, _ := json.Marshal(order)
+		orderCompositeKey, _ := stub.CreateCompositeKey("order", []string{order.Id})
+		_ = stub.PutState(orderCompositeKey, orderBytes)
+	}
+}
+
+// 写入供货商、物流商、买家和监管方账户，订单相关的交易需要校验账户角色
+func putAccounts(stub *shimtest.MockStub) {
+	var accountsName = [4]string{"供货商", "物流商", "买家", "监管方"}
+	var accountsRole = [4]string{roleSupplier, roleCarrier, roleBuyer, roleRegulator}
+	for i, val := range accountsName {
+		account := &Account{
+			Name:    val,
+			Id:      strconv.Itoa(i + 1),
+			Balance: 1000,
+			Role:    accountsRole[i],
+		}
+		// 序列化对象
+		accountBytes, _ := json.Marshal(account)
+		accountCompositeKey, _ := stub.CreateCompositeKey("account", []string{account.Id})
+		_ = stub.PutState(accountCompositeKey, accountBytes)
+	}
+}
+
+func GetTxArgs(stub *shimtest.MockStub, funcName string, number int) [][]byte {
+	switch funcName {
+	case "createCommodity":
+		// 1-7 依次将一个参数置空，8 缺少参数，9 参数完整
+		args := createCommodityArgs()
+		switch {
+		case number >= 1 && number <= 7:
+			args[number] = []byte("")
+			return args
+		case number == 8:
+			return args[:7]
+		case number == 9:
+			return args
+		default:
+			return [][]byte{}
+		}
+	case "createOrder":
+		// 1-12 依次将一个参数置空，13 缺少参数，14 商品不存在，15 参数完整
+		args := createOrderArgs("2021-10-01 08:00:00", "2021-10-04 08:00:00", "7")
+		switch {
+		case number >= 1 && number <= 12:
+			args[number] = []byte("")
+			return args
+		case number == 13:
+			return args[:12]
+		case number == 14:
+			args[1] = []byte("20211001999")
+			return args
+		case number == 15:
+			return args
+		default:
+			return [][]byte{}
+		}
+	case "updateOrderTemperature":
+		switch number {
+		case 1:
+			return [][]byte{
+				[]byte("updateOrderTemperature"),
+				[]byte(""),
+				[]byte("26"),
+				[]byte(time.Now().Format("2006-01-02 15:04:05")),
+				[]byte("2"),
+			}
+		case 2:
+			return [][]byte{
+				[]byte("updateOrderTemperature"),
+				[]byte("20211001101"),
+				[]byte(""),
+				[]byte(time.Now().Format("2006-01-02 15:04:05")),
+				[]byte("2"),
+			}
+		case 3:
+			return [][]byte{
+				[]byte("updateOrderTemperature"),
+				[]byte("20211001101"),
+				[]byte("26"),
+				[]byte(""),
+				[]byte("2"),
+			}
+		case 4:
+			return [][]byte{
+				[]byte("updateOrderTemperature"),
+				[]byte("20211001101"),
+			}
+		case 5:
+			return [][]byte{
+				[]byte("updateOrderTemperature"),
+				[]byte("20211001101"),
+				[]byte("26"),
+				[]byte(time.Now().Format("2006-01-02 15:04:05")),
+				[]byte("2"),
+			}
+		case 6:
+			return [][]byte{
+				[]byte("updateOrderTemperature"),
+				[]byte("20211001101"),
+				[]byte("-1.5"),
+				[]byte(time.Now().Format("2006-01-02 15:04:05")),
+				[]byte("2"),
+				[]byte("s1"),
+			}
+		default:
+			return [][]byte{}
+		}
+	case "updateOrderStatus":
+		switch number {
+		case 1:
+			return [][]byte{
+				[]byte("updateOrderStatus"),
+				[]byte(""),
+				[]byte("New"),
+				[]byte("2"),
+			}
+		case 2:
+			return [][]byte{
+				[]byte("updateOrderStatus"),
+				[]byte("20211001101"),
+				[]byte(""),
+				[]byte("2"),
+			}
+		case 3:
+			return [][]byte{
+				[]byte("updateOrderStatus"),
+				[]byte("20211001101"),
+			}
+		case 4:
+			return [][]byte{
+				[]byte("updateOrderStatus"),
+				[]byte("20211001101"),
+				[]byte("Processing"),
+				[]byte("2"),
+			}
+		case 5:
+			return [][]byte{
+				[]byte("updateOrderStatus"),
+				[]byte("20211001101"),
+				[]byte("Done"),
+				[]byte("3"),
+			}
+		case 6:
+			return [][]byte{
+				[]byte("updateOrderStatus"),
+				[]byte("20211001101"),
+				[]byte("Processing"),
+				[]byte("2"),
+				[]byte("s1"),
+			}
+		case 7:
+			return [][]byte{
+				[]byte("updateOrderStatus"),
+				[]byte("20211001101"),
+				[]byte("Shipped"),
+				[]byte("2"),
+			}
+		case 8:
+			return [][]byte{
+				[]byte("updateOrderStatus"),
+				[]byte("20211001999"),
+				[]byte("Processing"),
+				[]byte("2"),
+			}
+		case 9:
+			return [][]byte{
+				[]byte("updateOrderStatus"),
+				[]byte("20211001101"),
+				[]byte("Canceled"),
+				[]byte("3"),
+			}
+		default:
+			return [][]byte{}
+		}
+	default:
+		return [][]byte{}
+	}
+}
+
+func createCommodityArgs() [][]byte {
+	return [][]byte{
+		[]byte("createCommodity"),
+		[]byte("testBuy"),
+		[]byte("20211001001"),
+		[]byte("五角场"),
+		[]byte("-2"),
+		[]byte("0"),
+		[]byte("7.9"),
+		[]byte("1"),
+	}
+}
+
+func createOrderArgs(orderTime, deliverTime, quantity string) [][]byte {
+	return [][]byte{
+		[]byte("createOrder"),
+		[]byte("20211001001"),
+		[]byte("20211001101"),
+		[]byte(orderTime),
+		[]byte("New"),
+		[]byte("3"),
+		[]byte("1"),
+		[]byte("五角场"),
+		[]byte(deliverTime),
+		[]byte(quantity),
+		[]byte("2"),
+		[]byte("rate"),
+		[]byte("20"),
+	}
+}
+
+func testSomeTx(stub *shimtest.MockStub, status int, funcName string, number int) pb.Response {
+	if status != 1 {
+		putStateTransaction(stub, status-1)
+	}
+	res := invoke(stub, "1", GetTxArgs(stub, funcName, number))
+	return res
+}
+
+func loopCheck(stub *shimtest.MockStub, t *testing.T, start, end, status int, funcName string, flag string) {
+	loopCheckError(stub, t, start, end, status, funcName, flag, "")
+}
+
+// 同loopCheck，并检查错误信息中包含message，确认是被预期的校验拒绝
+func loopCheckError(stub *shimtest.MockStub, t *testing.T, start, end, status int, funcName string, flag string, message string) {
+	var res pb.Response
+	for i := start; i <= end; i++ {
+		res = testSomeTx(stub, status, funcName, i)
+		t.Log(res.Status)
+		t.Log(res.Message)
+		// 链码错误的状态码不小于ERRORTHRESHOLD，非运送中订单的温度返回StatusOrderClosed
+		if res.Status < shim.ERRORTHRESHOLD || !strings.Contains(res.Message, message) {
+			expectApi(2, funcName+flag)
+			t.FailNow()
+		}
+	}
+	expectApi(1, funcName+flag)
+}
+
+func getTr(stub *shimtest.MockStub, args []string) pb.Response {
+	stub.MockTransactionStart("1")
+	defer stub.MockTransactionEnd("1")
+
+	compositeKey, _ := stub.CreateCompositeKey(args[0], []string{args[1]})
+	trByte, err := stub.GetState(compositeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(trByte)
+}
+
+// 模拟背书节点，记录调用者身份和一次交易的写集
+// MockStub的交易参数不可导出且没有调用者身份，这里直接保存
+type endorsingStub struct {
+	*shimtest.MockStub
+	args     [][]byte
+	creator  []byte
+	writeSet map[string][]byte
+}
+
+func (stub *endorsingStub) GetCreator() ([]byte, error) {
+	return stub.creator, nil
+}
+
+func (stub *endorsingStub) GetArgs() [][]byte {
+	return stub.args
+}
+
+func (stub *endorsingStub) GetStringArgs() []string {
+	args := make([]string, 0, len(stub.args))
+	for _, val := range stub.args {
+		args = append(args, string(val))
+	}
+	return args
+}
+
+func (stub *endorsingStub) GetFunctionAndParameters() (string, []string) {
+	args := stub.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+func (stub *endorsingStub) PutState(key string, value []byte) error {
+	stub.writeSet[key] = value
+	return stub.MockStub.PutState(key, value)
+}
+
+func (stub *endorsingStub) DelState(key string) error {
+	stub.writeSet[key] = nil
+	return stub.MockStub.DelState(key)
+}
+
+// 在指定的交易时间执行交易，返回交易结果和写集
+func endorse(stub *shimtest.MockStub, txId string, txTime time.Time, args [][]byte) (pb.Response, map[string][]byte) {
+	stub.MockTransactionStart(txId)
+	defer stub.MockTransactionEnd(txId)
+	stub.TxTimestamp.Seconds = txTime.Unix()
+	stub.TxTimestamp.Nanos = int32(txTime.Nanosecond())
+
+	endorser := &endorsingStub{MockStub: stub, args: args, creator: newCreator(testMspId, claimedAccount(args)), writeSet: make(map[string][]byte)}
+	res := new(PerishableFood).Invoke(endorser)
+	return res, endorser.writeSet
+}
+
+// 测试中调用者所在组织
+const testMspId = "Organization1MSP"
+
+var (
+	creatorMutex sync.Mutex
+	creators     = make(map[string][]byte)
+)
+
+// 生成调用者身份，证书中带有Fabric CA格式的账户属性，accountId为空时为组织管理员
+func newCreator(mspId, accountId string) []byte {
+	if accountId == "" {
+		return newCreatorWith(mspId, pkix.Name{CommonName: "Admin", OrganizationalUnit: []string{roleAdmin}}, nil)
+	}
+	return newCreatorWith(mspId, pkix.Name{CommonName: "user" + accountId}, map[string]string{accountIdAttribute: accountId})
+}
+
+// 生成指定主题和Fabric CA属性的调用者身份
+func newCreatorWith(mspId string, subject pkix.Name, attrs map[string]string) []byte {
+	creatorMutex.Lock()
+	defer creatorMutex.Unlock()
+	cacheKey := fmt.Sprintf("%s/%s/%v", mspId, subject.String(), attrs)
+	if creator, ok := creators[cacheKey]; ok {
+		return creator
+	}
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if len(attrs) != 0 {
+		attrsBytes, _ := json.Marshal(map[string]map[string]string{"attrs": attrs})
+		template.ExtraExtensions = []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}, Value: attrsBytes}}
+	}
+	cert, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	creator, _ := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspId,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}),
+	})
+	creators[cacheKey] = creator
+	return creator
+}
+
+// 参数中声明的操作账户
+func claimedAccount(args [][]byte) string {
+	if len(args) == 0 {
+		return ""
+	}
+	index := map[string]int{
+		"createCommodity":        7,
+		"createOrder":            5,
+		"updateOrderStatus":      3,
+		"updateOrderTemperature": 4,
+		"updateAccount":          1,
+	}[string(args[0])]
+	if index == 0 || index >= len(args) {
+		return ""
+	}
+	return string(args[index])
+}
+
+// 以指定组织和账户的身份调用链码
+func invokeAs(stub *shimtest.MockStub, txId, mspId, accountId string, args [][]byte) pb.Response {
+	return invokeWith(stub, txId, newCreator(mspId, accountId), args)
+}
+
+// 以指定的调用者身份调用链码
+func invokeWith(stub *shimtest.MockStub, txId string, creator []byte, args [][]byte) pb.Response {
+	stub.MockTransactionStart(txId)
+	defer stub.MockTransactionEnd(txId)
+
+	caller := &endorsingStub{MockStub: stub, args: args, creator: creator, writeSet: make(map[string][]byte)}
+	return new(PerishableFood).Invoke(caller)
+}
+
+// 以参数中声明的账户身份调用链码
+func invoke(stub *shimtest.MockStub, txId string, args [][]byte) pb.Response {
+	return invokeAs(stub, txId, testMspId, claimedAccount(args), args)
+}
+
+// 复制账本状态，保证各背书节点的初始状态一致
+func cloneStub(source *shimtest.MockStub) *shimtest.MockStub {
+	stub := GetNewStub()
+	stub.MockTransactionStart("clone")
+	for e := source.Keys.Front(); e != nil; e = e.Next() {
+		key := e.Value.(string)
+		_ = stub.PutState(key, source.State[key])
+	}
+	stub.MockTransactionEnd("clone")
+	return stub
+}
+
+// 订单结算-多个背书节点执行同一交易得到相同的写集，结算结果可由账本数据重新计算
+func Test_settlementEndorsement(t *testing.T) {
+	source := GetNewStub()
+	putStateTransaction(source, 3)
+	putStateTransaction(source, 2)
+	peers := []*shimtest.MockStub{source, cloneStub(source), cloneStub(source)}
+
+	txTime := time.Date(2021, 10, 2, 8, 0, 0, 0, time.UTC)
+	txs := [][][]byte{
+		{[]byte("updateOrderStatus"), []byte("20211001101"), []byte("Processing"), []byte("2"), []byte("s1")},
+		{[]byte("updateOrderTemperature"), []byte("20211001101"), []byte("12"), []byte("2021-10-02 10:00:00"), []byte("2"), []byte("s1")},
+		{[]byte("updateOrderTemperature"), []byte("20211001101"), []byte("-3"), []byte("2021-10-02 12:00:00"), []byte("2"), []byte("s1")},
+		{[]byte("updateOrderStatus"), []byte("20211001101"), []byte("Done"), []byte("3")},
+	}
+	for i, args := range txs {
+		txId := strconv.Itoa(i + 1)
+		txTime = txTime.Add(time.Hour)
+
+		var expected map[string][]byte
+		for j, peer := range peers {
+			// 各节点的执行时间不同，但交易时间相同
+			time.Sleep(time.Millisecond)
+			res, writeSet := endorse(peer, txId, txTime, args)
+			if res.Status != shim.OK || len(writeSet) == 0 {
+				t.Log(res.Message)
+				expectApi(2, "settlementEndorsement")
+				t.FailNow()
+			}
+			if j == 0 {
+				expected = writeSet
+				continue
+			}
+			if len(writeSet) != len(expected) {
+				expectApi(2, "settlementEndorsement")
+				t.FailNow()
+			}
+			for key, value := range expected {
+				if !bytes.Equal(writeSet[key], value) {
+					t.Logf("tx %s write set mismatch on peer %d: %s", txId, j, key)
+					expectApi(2, "settlementEndorsement")
+					t.FailNow()
+				}
+			}
+		}
+	}
+
+	order := new(Order)
+	key, _ := source.CreateCompositeKey("order", []string{"20211001101"})
+	_ = json.Unmarshal(source.State[key], order)
+	settlement, _ := json.Marshal(calculateSettlement(order))
+	stored, _ := json.Marshal(order.Settlement)
+	if order.Status == "Done" && order.StatusTime.Equal(txTime) && bytes.Equal(settlement, stored) {
+		expectApi(1, "settlementEndorsement")
+	} else {
+		expectApi(2, "settlementEndorsement")
+		t.FailNow()
+	}
+}
+
+// 取出交易发出的链码事件，没有事件时返回空
+func nextEvent(stub *shimtest.MockStub) *Event {
+	select {
+	case val := <-stub.ChaincodeEventsChannel:
+		event := new(Event)
+		if err := json.Unmarshal(val.Payload, event); err != nil || event.Type != val.EventName {
+			return nil
+		}
+		return event
+	default:
+		return nil
+	}
+}
+
+// 链码事件-每个改变状态的交易发出一个事件，完成订单时发出结算事件，失败的交易不发出事件
+func Test_events(t *testing.T) {
+	stub := GetNewStub()
+	putStateTransaction(stub, 3)
+	putStateTransaction(stub, 1)
+
+	orderTime := time.Now().Format("2006-01-02 15:04:05")
+	deliverTime := time.Now().Add(72 * time.Hour).Format("2006-01-02 15:04:05")
+	txs := []struct {
+		args   [][]byte
+		expect func(event *Event) bool
+	}{
+		{[][]byte{[]byte("createCommodity"), []byte("testBuy"), []byte("20211001002"), []byte("五角场"), []byte("0"), []byte("10"), []byte("7.8"), []byte("1")}, func(event *Event) bool {
+			return event.Type == EventCommodityCreated && event.CommodityId == "20211001002" && event.SellerId == "1" && event.Amount == 7.8
+		}},
+		{createOrderArgs(orderTime, deliverTime, "10"), func(event *Event) bool {
+			return event.Type == EventOrderCreated && event.OrderId == "20211001101" && event.BuyerId == "3" && event.CarrierId == "2" && event.Quantity == 10 && event.Amount == 79
+		}},
+		{[][]byte{[]byte("updateOrderStatus"), []byte("20211001101"), []byte("Processing"), []byte("2"), []byte("s1")}, func(event *Event) bool {
+			return event.Type == EventOrderStatusChanged && event.Status == enumStatus.Processing && event.PreviousStatus == enumStatus.New && event.OperatorId == "2"
+		}},
+		{[][]byte{[]byte("updateOrderTemperature"), []byte("20211001101"), []byte("12"), []byte("2021-10-02 10:00:00"), []byte("2"), []byte("s1")}, func(event *Event) bool {
+			return event.Type == EventTemperatureRecorded && event.Temperature != nil && event.Temperature.Temperature == 12
+		}},
+		{[][]byte{[]byte("updateOrderStatus"), []byte("20211001101"), []byte("Done"), []byte("3")}, func(event *Event) bool {
+			return event.Type == EventSettlementCompleted && event.Status == enumStatus.Done && event.Settlement != nil &&
+				event.Amount == event.Settlement.Payment && event.SellerId == "1" && event.CarrierId == "2"
+		}},
+	}
+	for i, tx := range txs {
+		txId := strconv.Itoa(i + 1)
+		res := invoke(stub, txId, tx.args)
+		event := nextEvent(stub)
+		if res.Status != shim.OK || event == nil || event.TxId != txId || event.Time.IsZero() || !tx.expect(event) {
+			t.Logf("tx %s: %s, event %+v", txId, res.Message, event)
+			expectApi(2, "events")
+			t.FailNow()
+		}
+		if nextEvent(stub) != nil {
+			t.Logf("tx %s emitted more than one event", txId)
+			expectApi(2, "events")
+			t.FailNow()
+		}
+	}
+
+	// 已完成的订单不能再变更状态，也不发出事件
+	res := invoke(stub, "6", [][]byte{[]byte("updateOrderStatus"), []byte("20211001101"), []byte("Canceled"), []byte("3")})
+	if res.Status == shim.OK || nextEvent(stub) != nil {
+		expectApi(2, "events")
+		t.FailNow()
+	}
+	expectApi(1, "events")
+}
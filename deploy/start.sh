@@ -29,6 +29,8 @@ configtxgen -profile ThreeOrgsChannel -outputAnchorPeersUpdate ./channel-artifac
 
 # 四、启动区块链网络
 echo "区块链 ： 启动"
+# CA的私钥文件名每次生成都不同，导出给docker-compose使用
+export ORG1_CA_PRIVATE_KEY=$(basename $(ls crypto-config/peerOrganizations/organization1.gdzce.cn/ca/*_sk))
 docker-compose -f docker-compose-cli.yaml up -d        # 按照docker-compose.yaml的配置启动区块链网络并在后台运行
 echo "正在等待节点的启动完成，等待10秒"
 sleep 10                    # 启动整个区块链网络需要一点时间，所以此处等待15s，让区块链网络完全启动